@@ -17,7 +17,11 @@ package iacrules
 import (
 	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
 
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/bundle"
 	initWorkflow "github.com/khulnasoft-lab/cli-extension-iac-rules/internal/init"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/inspect"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/listbundles"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/pull"
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/push"
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/repl"
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/test"
@@ -33,8 +37,20 @@ func Init(e workflow.Engine) error {
 	if err := push.RegisterWorkflows(e); err != nil {
 		return err
 	}
+	if err := listbundles.RegisterWorkflows(e); err != nil {
+		return err
+	}
+	if err := pull.RegisterWorkflows(e); err != nil {
+		return err
+	}
 	if err := repl.RegisterWorkflows(e); err != nil {
 		return err
 	}
+	if err := bundle.RegisterWorkflows(e); err != nil {
+		return err
+	}
+	if err := inspect.RegisterWorkflows(e); err != nil {
+		return err
+	}
 	return nil
 }
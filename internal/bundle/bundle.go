@@ -0,0 +1,342 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	pebundle "github.com/khulnasoft/policy-engine/pkg/bundle"
+	"github.com/khulnasoft/policy-engine/pkg/bundle/base"
+	v1 "github.com/khulnasoft/policy-engine/pkg/bundle/v1"
+	"github.com/khulnasoft/policy-engine/pkg/data"
+	"github.com/khulnasoft/policy-engine/pkg/engine"
+	"github.com/open-policy-agent/opa/ast"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+)
+
+// Bundle is a validated rule bundle together with its tarball.
+type Bundle struct {
+	bundle pebundle.Bundle
+	targz  []byte
+	// sources contains the rego source of each module by path.
+	sources map[string][]byte
+}
+
+// Build builds and validates the rule bundle for the given project.
+func Build(prj *project.Project) (*Bundle, error) {
+	reader := pebundle.NewDirReader(prj.Path())
+	built, err := pebundle.BuildBundle(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := built.Validate(); err != nil {
+		return nil, err
+	}
+	sources, err := readSources(reader, built.Modules())
+	if err != nil {
+		return nil, err
+	}
+	targz, err := writeTarGz(built, sources)
+	if err != nil {
+		return nil, err
+	}
+	return &Bundle{
+		bundle:  built,
+		targz:   targz,
+		sources: sources,
+	}, nil
+}
+
+// Read reads and validates a rule bundle tarball. The path is only used in
+// error messages.
+func Read(path string, r io.Reader) (*Bundle, error) {
+	targz, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := pebundle.NewTarGzReader(path, bytes.NewReader(targz))
+	if err != nil {
+		return nil, err
+	}
+	read, err := pebundle.ReadBundle(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle %s: %w", path, err)
+	}
+	sources, err := readSources(reader, read.Modules())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle %s: %w", path, err)
+	}
+	return &Bundle{
+		bundle:  read,
+		targz:   targz,
+		sources: sources,
+	}, nil
+}
+
+// readSources returns the contents of the files that the given modules were
+// parsed from, by module path.
+func readSources(reader base.Reader, modules map[string]*ast.Module) (map[string][]byte, error) {
+	sources := map[string][]byte{}
+	err := reader.WalkFiles(func(path string, f io.Reader) error {
+		path = filepath.ToSlash(filepath.Clean(path))
+		if _, ok := modules[path]; !ok {
+			return nil
+		}
+		raw, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		sources[path] = raw
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// writeTarGz writes the bundle as a tarball. This mirrors policy-engine's
+// TarGzWriter, but it writes the source of each module rather than the
+// string representation of its AST, which loses formatting and comments.
+func writeTarGz(b pebundle.Bundle, sources map[string][]byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	modules := b.Modules()
+	paths := make([]string, 0, len(modules))
+	for path := range modules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		contents, ok := sources[path]
+		if !ok {
+			contents = []byte(modules[path].String())
+		}
+		if err := writeTarFile(tw, path, contents); err != nil {
+			return nil, err
+		}
+	}
+	document, err := json.Marshal(b.Document())
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTarFile(tw, "data.json", document); err != nil {
+		return nil, err
+	}
+	manifest, err := json.Marshal(b.Manifest())
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTarFile(tw, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeTarFile(tw *tar.Writer, path string, contents []byte) error {
+	hdr := &tar.Header{
+		Name:     path,
+		Mode:     0600,
+		Typeflag: tar.TypeReg,
+		Size:     int64(len(contents)),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(contents)
+	return err
+}
+
+// TarGz returns the bundle's tarball.
+func (b *Bundle) TarGz() []byte {
+	return b.targz
+}
+
+// ContentHash returns a hash of the bundle's rego modules, data and manifest.
+// Unlike the checksum of the tarball, it doesn't depend on the order in which
+// files were written, so it only changes when the bundle's contents change.
+func (b *Bundle) ContentHash() (string, error) {
+	h := sha256.New()
+	modules := b.bundle.Modules()
+	paths := make([]string, 0, len(modules))
+	for path := range modules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(h, "%s\x00%s\x00", path, modules[path].String())
+	}
+	document, err := json.Marshal(b.bundle.Document())
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "data.json\x00%s\x00", document)
+	manifest, err := json.Marshal(b.bundle.Manifest())
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "manifest.json\x00%s\x00", manifest)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// Name returns the project name recorded in the bundle's manifest.
+func (b *Bundle) Name() string {
+	return b.manifest().Name
+}
+
+// Modules returns the bundle's rego modules by path.
+func (b *Bundle) Modules() map[string]*ast.Module {
+	return b.bundle.Modules()
+}
+
+// Source returns the rego source of the module at the given path, as it was
+// written to the bundle. It returns false when the source isn't known.
+func (b *Bundle) Source(path string) ([]byte, bool) {
+	source, ok := b.sources[path]
+	return source, ok
+}
+
+// HasData returns whether the bundle contains any data documents.
+func (b *Bundle) HasData() bool {
+	return len(b.bundle.Document()) > 0
+}
+
+// Provider returns a data provider for the bundle's rego code and data.
+func (b *Bundle) Provider() data.Provider {
+	return b.bundle.Provider()
+}
+
+// RuleMetadata returns a map of rule ID to rule metadata from all rules in the
+// bundle.
+func (b *Bundle) RuleMetadata(ctx context.Context) (map[string]project.RuleMetadata, error) {
+	eng, err := b.engine(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return project.EngineRuleMetadata(ctx, eng)
+}
+
+func (b *Bundle) engine(ctx context.Context) (*engine.Engine, error) {
+	eng := engine.NewEngine(ctx, &engine.EngineOptions{
+		Providers: []data.Provider{b.Provider()},
+	})
+	if len(eng.InitializationErrors) > 0 {
+		return nil, &multierror.Error{
+			Errors: eng.InitializationErrors,
+		}
+	}
+	return eng, nil
+}
+
+func (b *Bundle) manifest() v1.Manifest {
+	m, _ := b.bundle.Manifest().(v1.Manifest)
+	return m
+}
+
+// rule is a rule in a bundle together with the rego code of its package.
+type rule struct {
+	metadata project.RuleMetadata
+	code     string
+}
+
+// rules returns a map of rule ID to rule. Rego tests are not included in the
+// rule's code. A nil bundle has no rules.
+func (b *Bundle) rules(ctx context.Context) (map[string]rule, error) {
+	if b == nil {
+		return map[string]rule{}, nil
+	}
+	eng, err := b.engine(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := project.EngineRuleMetadata(ctx, eng)
+	if err != nil {
+		return nil, err
+	}
+	packages, err := project.RulePackages(ctx, eng)
+	if err != nil {
+		return nil, err
+	}
+	modules := b.bundle.Modules()
+	paths := make([]string, 0, len(modules))
+	for path := range modules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	code := map[string][]string{}
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.rego") {
+			continue
+		}
+		pkg := modules[path].Package.Path.String()
+		code[pkg] = append(code[pkg], codeWithoutMetadata(modules[path]))
+	}
+	rules := map[string]rule{}
+	for id, md := range metadata {
+		rules[id] = rule{
+			metadata: md,
+			code:     strings.Join(code[packages[id]], "\n"),
+		}
+	}
+	return rules, nil
+}
+
+// codeWithoutMetadata returns the rego code of a module without its metadata
+// rule, so that metadata changes aren't reported as code changes.
+func codeWithoutMetadata(mod *ast.Module) string {
+	mod = mod.Copy()
+	rules := []*ast.Rule{}
+	for _, r := range mod.Rules {
+		if r.Head.Name != "metadata" {
+			rules = append(rules, r)
+		}
+	}
+	mod.Rules = rules
+	return mod.String()
+}
+
+// lib returns a map of the path of each lib file to its rego code. A nil
+// bundle has no lib files.
+func (b *Bundle) lib() map[string]string {
+	lib := map[string]string{}
+	if b == nil {
+		return lib
+	}
+	for path, mod := range b.bundle.Modules() {
+		if strings.HasPrefix(path, "lib/") {
+			lib[path] = mod.String()
+		}
+	}
+	return lib
+}
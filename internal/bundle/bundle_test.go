@@ -0,0 +1,149 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/testutil"
+)
+
+func writeTestProject(t *testing.T, files map[string]string) *project.Project {
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, files)
+	prj, err := project.FromDir(afero.NewOsFs(), dir)
+	require.NoError(t, err)
+	return prj
+}
+
+func TestBuildAndRead(t *testing.T) {
+	ctx := context.Background()
+	built, err := Build(writeTestProject(t, testutil.ProjectFiles()))
+	require.NoError(t, err)
+
+	read, err := Read("bundle.tar.gz", bytes.NewReader(built.TarGz()))
+	require.NoError(t, err)
+	assert.Equal(t, built.TarGz(), read.TarGz())
+	builtHash, err := built.ContentHash()
+	require.NoError(t, err)
+	readHash, err := read.ContentHash()
+	require.NoError(t, err)
+	assert.Equal(t, builtHash, readHash)
+
+	summary, err := read.Summary(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Test", summary.Name)
+	assert.Equal(t, "v1", summary.BundleFormatVersion)
+	assert.Equal(t, len(built.TarGz()), summary.Size)
+	assert.Equal(t, readHash, summary.ContentHash)
+	assert.Equal(t, []string{"lib/utils.rego"}, summary.Lib)
+	assert.Equal(t, []project.RuleMetadata{
+		{
+			ID:          "TEST-001",
+			Severity:    "high",
+			Title:       "S3 bucket has the word 'bucket' in its name",
+			Description: "The word 'bucket' is redundant in a bucket name.",
+			Product:     []string{"iac"},
+		},
+	}, summary.Rules)
+}
+
+func TestBundleSource(t *testing.T) {
+	files := testutil.ProjectFiles()
+	files["lib/utils.rego"] = "package lib.utils\n\n# Hand-formatted, with a comment.\nis_bucket(name) { contains(name, \"bucket\") }\n"
+	built, err := Build(writeTestProject(t, files))
+	require.NoError(t, err)
+	read, err := Read("bundle.tar.gz", bytes.NewReader(built.TarGz()))
+	require.NoError(t, err)
+	for _, b := range []*Bundle{built, read} {
+		for _, path := range []string{"rules/TEST_001/main.rego", "lib/utils.rego"} {
+			source, ok := b.Source(path)
+			assert.True(t, ok, path)
+			assert.Equal(t, files[path], string(source), path)
+		}
+		_, ok := b.Source("lib/missing.rego")
+		assert.False(t, ok)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	_, err := Read("bundle.tar.gz", bytes.NewReader([]byte("not a tarball")))
+	assert.Error(t, err)
+}
+
+func TestCompare(t *testing.T) {
+	ctx := context.Background()
+	before, err := Build(writeTestProject(t, testutil.ProjectFiles()))
+	require.NoError(t, err)
+
+	t.Run("unchanged", func(t *testing.T) {
+		after, err := Build(writeTestProject(t, testutil.ProjectFiles()))
+		require.NoError(t, err)
+		c, err := Compare(ctx, before, after)
+		require.NoError(t, err)
+		assert.False(t, c.Changed())
+		beforeHash, err := before.ContentHash()
+		require.NoError(t, err)
+		afterHash, err := after.ContentHash()
+		require.NoError(t, err)
+		assert.Equal(t, beforeHash, afterHash)
+	})
+
+	t.Run("new bundle", func(t *testing.T) {
+		c, err := Compare(ctx, nil, before)
+		require.NoError(t, err)
+		assert.Len(t, c.Added, 1)
+		assert.Equal(t, []string{"lib/utils.rego"}, c.LibAdded)
+		assert.Empty(t, c.Removed)
+		assert.Empty(t, c.Modified)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		files := testutil.ProjectFiles()
+		files["rules/TEST_001/main.rego"] = strings.ReplaceAll(testutil.Rule, `"high"`, `"low"`)
+		files["rules/TEST_002/main.rego"] = strings.NewReplacer("TEST_001", "TEST_002", "TEST-001", "TEST-002").Replace(testutil.Rule)
+		files["lib/utils.rego"] = testutil.Lib + "\nis_logging(name) {\n\tcontains(name, \"logging\")\n}\n"
+		after, err := Build(writeTestProject(t, files))
+		require.NoError(t, err)
+		c, err := Compare(ctx, before, after)
+		require.NoError(t, err)
+		assert.True(t, c.Changed())
+		assert.Len(t, c.Added, 1)
+		assert.Equal(t, "TEST-002", c.Added[0].ID)
+		assert.Empty(t, c.Removed)
+		assert.Equal(t, []RuleChange{
+			{
+				ID: "TEST-001",
+				Metadata: []MetadataChange{
+					{Field: "severity", Old: "high", New: "low"},
+				},
+			},
+		}, c.Modified)
+		assert.Equal(t, []string{"lib/utils.rego"}, c.LibModified)
+		beforeHash, err := before.ContentHash()
+		require.NoError(t, err)
+		afterHash, err := after.ContentHash()
+		require.NoError(t, err)
+		assert.NotEqual(t, beforeHash, afterHash)
+	})
+}
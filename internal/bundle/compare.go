@@ -0,0 +1,195 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+)
+
+// Comparison lists the differences between two rule bundles.
+type Comparison struct {
+	Added       []project.RuleMetadata `json:"added"`
+	Removed     []project.RuleMetadata `json:"removed"`
+	Modified    []RuleChange           `json:"modified"`
+	LibAdded    []string               `json:"lib_added"`
+	LibRemoved  []string               `json:"lib_removed"`
+	LibModified []string               `json:"lib_modified"`
+}
+
+// RuleChange describes how a rule differs between two bundles.
+type RuleChange struct {
+	ID string `json:"id"`
+	// CodeChanged is set when the rego code of the rule's package changed.
+	// Changes to rego tests are ignored.
+	CodeChanged bool             `json:"code_changed"`
+	Metadata    []MetadataChange `json:"metadata,omitempty"`
+}
+
+// MetadataChange is a change to a single rule metadata field.
+type MetadataChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Changed returns whether the bundles differ in any of their rules or lib
+// files.
+func (c *Comparison) Changed() bool {
+	return len(c.Added) > 0 ||
+		len(c.Removed) > 0 ||
+		len(c.Modified) > 0 ||
+		len(c.LibAdded) > 0 ||
+		len(c.LibRemoved) > 0 ||
+		len(c.LibModified) > 0
+}
+
+// Compare returns the changes from the before bundle to the after bundle. A
+// nil bundle is treated as an empty one, e.g. when a bundle is first created.
+func Compare(ctx context.Context, before, after *Bundle) (*Comparison, error) {
+	beforeRules, err := before.rules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	afterRules, err := after.rules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := &Comparison{
+		Added:       []project.RuleMetadata{},
+		Removed:     []project.RuleMetadata{},
+		Modified:    []RuleChange{},
+		LibAdded:    []string{},
+		LibRemoved:  []string{},
+		LibModified: []string{},
+	}
+	for _, id := range sortedKeys(beforeRules, afterRules) {
+		o, inOld := beforeRules[id]
+		n, inNew := afterRules[id]
+		switch {
+		case !inOld:
+			c.Added = append(c.Added, n.metadata)
+		case !inNew:
+			c.Removed = append(c.Removed, o.metadata)
+		default:
+			change := RuleChange{
+				ID:          id,
+				CodeChanged: o.code != n.code,
+				Metadata:    compareMetadata(o.metadata, n.metadata),
+			}
+			if change.CodeChanged || len(change.Metadata) > 0 {
+				c.Modified = append(c.Modified, change)
+			}
+		}
+	}
+	beforeLib := before.lib()
+	afterLib := after.lib()
+	for _, path := range sortedKeys(beforeLib, afterLib) {
+		o, inOld := beforeLib[path]
+		n, inNew := afterLib[path]
+		switch {
+		case !inOld:
+			c.LibAdded = append(c.LibAdded, path)
+		case !inNew:
+			c.LibRemoved = append(c.LibRemoved, path)
+		case o != n:
+			c.LibModified = append(c.LibModified, path)
+		}
+	}
+	return c, nil
+}
+
+// Print writes a human-readable version of the comparison to w.
+func (c *Comparison) Print(w io.Writer) {
+	if !c.Changed() {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+	for _, r := range c.Added {
+		fmt.Fprintf(w, "+ %s [%s] %s\n", r.ID, r.Severity, r.Title)
+	}
+	for _, r := range c.Removed {
+		fmt.Fprintf(w, "- %s [%s] %s\n", r.ID, r.Severity, r.Title)
+	}
+	for _, r := range c.Modified {
+		fmt.Fprintf(w, "~ %s\n", r.ID)
+		for _, m := range r.Metadata {
+			fmt.Fprintf(w, "    %s: %q → %q\n", m.Field, m.Old, m.New)
+		}
+		if r.CodeChanged {
+			fmt.Fprintln(w, "    rego code changed")
+		}
+	}
+	for _, path := range c.LibAdded {
+		fmt.Fprintf(w, "+ %s\n", path)
+	}
+	for _, path := range c.LibRemoved {
+		fmt.Fprintf(w, "- %s\n", path)
+	}
+	for _, path := range c.LibModified {
+		fmt.Fprintf(w, "~ %s\n", path)
+	}
+	fmt.Fprintf(w,
+		"%d rules added, %d removed, %d modified; %d lib files added, %d removed, %d modified.\n",
+		len(c.Added), len(c.Removed), len(c.Modified),
+		len(c.LibAdded), len(c.LibRemoved), len(c.LibModified),
+	)
+}
+
+func compareMetadata(before, after project.RuleMetadata) []MetadataChange {
+	fields := []struct {
+		name  string
+		value func(project.RuleMetadata) string
+	}{
+		{"severity", func(m project.RuleMetadata) string { return m.Severity }},
+		{"title", func(m project.RuleMetadata) string { return m.Title }},
+		{"description", func(m project.RuleMetadata) string { return m.Description }},
+		{"product", func(m project.RuleMetadata) string { return strings.Join(m.Product, ", ") }},
+		{"category", func(m project.RuleMetadata) string { return m.Category }},
+		{"labels", func(m project.RuleMetadata) string { return strings.Join(m.Labels, ", ") }},
+		{"platform", func(m project.RuleMetadata) string { return strings.Join(m.Platform, ", ") }},
+		{"service_group", func(m project.RuleMetadata) string { return m.ServiceGroup }},
+	}
+	var changes []MetadataChange
+	for _, f := range fields {
+		o := f.value(before)
+		n := f.value(after)
+		if o != n {
+			changes = append(changes, MetadataChange{Field: f.name, Old: o, New: n})
+		}
+	}
+	return changes
+}
+
+func sortedKeys[V any](a, b map[string]V) []string {
+	keys := map[string]struct{}{}
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
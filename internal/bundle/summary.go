@@ -0,0 +1,91 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	pebundle "github.com/khulnasoft/policy-engine/pkg/bundle"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+)
+
+// Summary describes the contents of a rule bundle.
+type Summary struct {
+	Name                string                 `json:"name"`
+	BundleFormatVersion string                 `json:"bundle_format_version"`
+	PolicyEngineVersion string                 `json:"policy_engine_version"`
+	Checksum            string                 `json:"checksum"`
+	ContentHash         string                 `json:"content_hash"`
+	Size                int                    `json:"size"`
+	Rules               []project.RuleMetadata `json:"rules"`
+	Lib                 []string               `json:"lib"`
+}
+
+// Summary returns a summary of the bundle's manifest, rules and lib files.
+// Rules are sorted by ID.
+func (b *Bundle) Summary(ctx context.Context) (*Summary, error) {
+	metadata, err := b.RuleMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	contentHash, err := b.ContentHash()
+	if err != nil {
+		return nil, err
+	}
+	manifest := b.manifest()
+	summary := &Summary{
+		Name:                manifest.Name,
+		BundleFormatVersion: manifest.BundleFormatVersion,
+		PolicyEngineVersion: manifest.PolicyEngineVersion,
+		Checksum:            pebundle.Checksum(b.targz),
+		ContentHash:         contentHash,
+		Size:                len(b.targz),
+		Rules:               []project.RuleMetadata{},
+		Lib:                 []string{},
+	}
+	for _, md := range metadata {
+		summary.Rules = append(summary.Rules, md)
+	}
+	sort.Slice(summary.Rules, func(i, j int) bool {
+		return summary.Rules[i].ID < summary.Rules[j].ID
+	})
+	for path := range b.lib() {
+		summary.Lib = append(summary.Lib, path)
+	}
+	sort.Strings(summary.Lib)
+	return summary, nil
+}
+
+// Print writes a human-readable version of the summary to w.
+func (s *Summary) Print(w io.Writer) {
+	fmt.Fprintf(w, "Bundle:         %s\n", s.Name)
+	fmt.Fprintf(w, "Format version: %s\n", s.BundleFormatVersion)
+	fmt.Fprintf(w, "Policy engine:  %s\n", s.PolicyEngineVersion)
+	fmt.Fprintf(w, "Size:           %d bytes\n", s.Size)
+	fmt.Fprintf(w, "Checksum:       %s\n", s.Checksum)
+	fmt.Fprintf(w, "Content hash:   %s\n", s.ContentHash)
+	fmt.Fprintf(w, "Rules (%d):\n", len(s.Rules))
+	for _, r := range s.Rules {
+		fmt.Fprintf(w, "  %s [%s] %s\n", r.ID, r.Severity, r.Title)
+	}
+	fmt.Fprintf(w, "Lib files (%d):\n", len(s.Lib))
+	for _, l := range s.Lib {
+		fmt.Fprintf(w, "  %s\n", l)
+	}
+}
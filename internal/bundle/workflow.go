@@ -0,0 +1,92 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
+)
+
+const flagOutput = "output"
+
+// dataTypeSummary is the type of the workflow data describing the contents of
+// a bundle.
+const dataTypeSummary = "summary"
+
+func RegisterWorkflows(e workflow.Engine) error {
+	workflowID := workflow.NewWorkflowIdentifier("iac.rules.bundle")
+	flagset := pflag.NewFlagSet("vulnmap-cli-extension-iac-rules-bundle", pflag.ExitOnError)
+
+	flagset.String(flagOutput, "bundle.tar.gz", "Path to write the rule bundle to")
+	utils.AddProjectDirFlag(flagset)
+
+	c := workflow.ConfigurationOptionsFromFlagset(flagset)
+
+	if _, err := e.Register(workflowID, c, bundleWorkflow); err != nil {
+		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
+	}
+	return nil
+}
+
+func bundleWorkflow(
+	ictx workflow.InvocationContext,
+	_ []workflow.Data,
+) ([]workflow.Data, error) {
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
+	logger := ictx.GetLogger()
+	config := ictx.GetConfiguration()
+	output := config.GetString(flagOutput)
+	if output == "" {
+		return nil, fmt.Errorf("--%s must not be empty", flagOutput)
+	}
+
+	fsys := afero.NewOsFs()
+	root, err := project.Discover(fsys, config.GetString(utils.FlagProjectDir))
+	if err != nil {
+		return nil, err
+	}
+	prj, err := project.FromDir(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+	built, err := Build(prj)
+	if err != nil {
+		return nil, err
+	}
+	logger.Println("validated bundle")
+	summary, err := built.Summary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.WriteFileAtomic(fsys, output, built.TarGz(), 0644); err != nil {
+		return nil, err
+	}
+
+	summary.Print(os.Stderr)
+	fmt.Fprintf(os.Stderr, "Wrote rule bundle to %s.\n", output)
+	data, err := utils.NewJSONData(ictx.GetWorkflowIdentifier(), dataTypeSummary, summary)
+	if err != nil {
+		return nil, err
+	}
+	return []workflow.Data{data}, nil
+}
@@ -15,13 +15,15 @@
 package init
 
 import (
+	"context"
+
 	"github.com/rs/zerolog"
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
 )
 
-func checkProject(proj *project.Project, logger *zerolog.Logger) {
+func checkProject(ctx context.Context, proj *project.Project, logger *zerolog.Logger) {
 	// Test if we'll be able to query the project for Rule IDs and such
-	_, err := proj.RuleMetadata()
+	_, err := proj.RuleMetadata(ctx)
 	if err != nil {
 		logger.Warn().Msgf("Found errors in this project. This tool is still usable, but we'll be unable to populate some menus: %s", err.Error())
 	}
@@ -17,12 +17,14 @@ package forms
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/erikgeiser/promptkit/confirmation"
 	"github.com/erikgeiser/promptkit/selection"
 	"github.com/erikgeiser/promptkit/textinput"
 	"github.com/rs/zerolog"
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
 	"github.com/khulnasoft/policy-engine/pkg/input"
 	"github.com/khulnasoft/policy-engine/pkg/input/cloudapi"
 )
@@ -43,6 +45,9 @@ type (
 		Name    string
 		Fields  CloudSpecFields
 		Logger  *zerolog.Logger
+		Context context.Context
+		// Timeout limits the time spent fetching resources from the API.
+		Timeout time.Duration
 	}
 )
 
@@ -51,7 +56,12 @@ func (f *CloudSpecForm) Run() error {
 		return err
 	}
 
-	ctx := context.Background()
+	ctx := f.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := utils.WithTimeout(ctx, f.Timeout)
+	defer cancel()
 	loader := input.CloudLoader{
 		Client: f.Client,
 	}
@@ -30,6 +30,20 @@ func allInputTypes() []string {
 	}
 }
 
+// specInputTypes returns the input types that rule specs can be created for.
+// These include Terraform plan JSON, which rules with the tf input type are
+// also evaluated against.
+func specInputTypes() []string {
+	return []string{
+		input.Terraform.Name,
+		input.TerraformPlan.Name,
+		input.CloudScan.Name,
+		input.Kubernetes.Name,
+		input.CloudFormation.Name,
+		input.Arm.Name,
+	}
+}
+
 func iacInputTypes() []string {
 	return []string{
 		input.Terraform.Name,
@@ -15,6 +15,7 @@
 package forms
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"regexp"
@@ -42,6 +43,7 @@ type (
 		Metadata  *project.RuleMetadata
 		Fields    MultiResourceRuleFields
 		Logger    *zerolog.Logger
+		Context   context.Context
 	}
 )
 
@@ -139,7 +141,7 @@ func (f *MultiResourceRuleForm) promptRelation() error {
 	const addNewRelation = "Add a new relation"
 	const enterManually = "Enter manually"
 	choices := []string{addNewRelation, enterManually}
-	relations, err := f.Project.RelationNames()
+	relations, err := f.Project.RelationNames(f.Context)
 	if err != nil {
 		// we don't want to crash if there's a compilation or execution error.
 		// instead, we'll still allow users to add a new relation or enter in a
@@ -15,6 +15,8 @@
 package forms
 
 import (
+	"context"
+
 	"github.com/erikgeiser/promptkit/confirmation"
 	"github.com/erikgeiser/promptkit/selection"
 	"github.com/erikgeiser/promptkit/textinput"
@@ -38,6 +40,8 @@ type (
 		Project *project.Project
 		Fields  RuleFields
 		Logger  *zerolog.Logger
+		// Context applies to the queries made against the project's rules.
+		Context context.Context
 	}
 )
 
@@ -72,7 +76,7 @@ func (f *RuleForm) promptRuleID() error {
 	}
 
 	var existingIDs []string
-	metadata, err := f.Project.RuleMetadata()
+	metadata, err := f.Project.RuleMetadata(f.Context)
 	if err == nil {
 		for id := range metadata {
 			existingIDs = append(existingIDs, id)
@@ -219,6 +223,7 @@ func (f *RuleForm) runSubForm() error {
 			InputType: f.Fields.InputType,
 			Metadata:  metadata,
 			Logger:    f.Logger,
+			Context:   f.Context,
 		}
 	} else {
 		f.Fields.SubForm = &SingleResourceRuleForm{
@@ -15,7 +15,9 @@
 package forms
 
 import (
+	"context"
 	"sort"
+	"time"
 
 	"github.com/erikgeiser/promptkit/selection"
 	"github.com/erikgeiser/promptkit/textinput"
@@ -38,6 +40,10 @@ type (
 		OrgID   string
 		Fields  SpecFields
 		Logger  *zerolog.Logger
+		// Context applies to the queries made against the project's rules
+		// and, with Timeout, to the requests made by the cloud spec form.
+		Context context.Context
+		Timeout time.Duration
 	}
 )
 
@@ -60,6 +66,8 @@ func (f *SpecForm) Run() error {
 			RuleID:  f.Fields.RuleID,
 			Name:    f.Fields.Name,
 			Logger:  f.Logger,
+			Context: f.Context,
+			Timeout: f.Timeout,
 		}
 		return form.Run()
 	} else {
@@ -79,7 +87,7 @@ func (f *SpecForm) promptRuleID() error {
 	}
 
 	var choices []string
-	metadata, err := f.Project.RuleMetadata()
+	metadata, err := f.Project.RuleMetadata(f.Context)
 	if err == nil {
 		for id := range metadata {
 			choices = append(choices, id)
@@ -129,16 +137,16 @@ func (f *SpecForm) promptInputType() error {
 	}
 
 	var choices []string
-	defaultInputType, err := f.Project.InputTypeForRule(f.Fields.RuleID)
+	defaultInputType, err := f.Project.InputTypeForRule(f.Context, f.Fields.RuleID)
 	if err == nil && defaultInputType != "" {
 		choices = []string{defaultInputType}
-		for _, t := range allInputTypes() {
+		for _, t := range specInputTypes() {
 			if t != defaultInputType {
 				choices = append(choices, t)
 			}
 		}
 	} else {
-		choices = allInputTypes()
+		choices = specInputTypes()
 	}
 	prompt := selection.New("Input type:", choices)
 	choice, err := prompt.RunPrompt()
@@ -34,11 +34,17 @@ var k8sTmpl []byte
 //go:embed spectemplates/infra.tf
 var tfTmpl []byte
 
+//go:embed spectemplates/tfplan.json
+var tfPlanTmpl []byte
+
 func specForInputType(inputType string, name string) (filename string, contents []byte) {
 	switch inputType {
 	case input.Terraform.Name:
 		filename = addExtIfNeeded(name, ".tf")
 		contents = tfTmpl
+	case input.TerraformPlan.Name:
+		filename = addExtIfNeeded(name, ".json")
+		contents = tfPlanTmpl
 	case input.Kubernetes.Name:
 		filename = addExtIfNeeded(name, ".yaml")
 		contents = k8sTmpl
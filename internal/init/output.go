@@ -0,0 +1,115 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package init
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
+	"github.com/spf13/afero"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
+)
+
+// dataTypeFiles is the type of the workflow data listing the files that were
+// written.
+const dataTypeFiles = "files"
+
+// initResult lists the files that an init workflow created or updated.
+type initResult struct {
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+}
+
+// recordingFs is an afero.Fs that records the files written through it. The
+// forms write to the project in several places, so this is simpler than
+// having each of them report their paths.
+type recordingFs struct {
+	afero.Fs
+	seen   map[string]bool
+	result initResult
+}
+
+func newRecordingFs(fsys afero.Fs) *recordingFs {
+	return &recordingFs{
+		Fs:   fsys,
+		seen: map[string]bool{},
+		result: initResult{
+			Created: []string{},
+			Updated: []string{},
+		},
+	}
+}
+
+func (r *recordingFs) Create(name string) (afero.File, error) {
+	r.record(name)
+	return r.Fs.Create(name)
+}
+
+func (r *recordingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		r.record(name)
+	}
+	return r.Fs.OpenFile(name, flag, perm)
+}
+
+// Rename records the new name, since files are written to a temporary file
+// first and then renamed into place.
+func (r *recordingFs) Rename(oldname, newname string) error {
+	if r.seen[oldname] {
+		r.forget(oldname)
+		r.record(newname)
+	}
+	return r.Fs.Rename(oldname, newname)
+}
+
+func (r *recordingFs) forget(name string) {
+	delete(r.seen, name)
+	r.result.Created = removeString(r.result.Created, name)
+	r.result.Updated = removeString(r.result.Updated, name)
+}
+
+func removeString(list []string, s string) []string {
+	out := list[:0]
+	for _, e := range list {
+		if e != s {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (r *recordingFs) record(name string) {
+	if r.seen[name] {
+		return
+	}
+	r.seen[name] = true
+	if _, err := r.Fs.Stat(name); errors.Is(err, fs.ErrNotExist) {
+		r.result.Created = append(r.result.Created, name)
+	} else {
+		r.result.Updated = append(r.result.Updated, name)
+	}
+}
+
+// data returns the recorded files as workflow data.
+func (r *recordingFs) data(ictx workflow.InvocationContext) ([]workflow.Data, error) {
+	data, err := utils.NewJSONData(ictx.GetWorkflowIdentifier(), dataTypeFiles, r.result)
+	if err != nil {
+		return nil, err
+	}
+	return []workflow.Data{data}, nil
+}
@@ -15,11 +15,11 @@
 package init
 
 import (
-	"os"
 	"path/filepath"
 
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/init/forms"
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
 	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
 	"github.com/spf13/afero"
 )
@@ -29,15 +29,19 @@ func ProjectWorkflow(
 	_ []workflow.Data,
 ) ([]workflow.Data, error) {
 	logger := ictx.GetEnhancedLogger()
-	wd, err := os.Getwd()
+	fsys := newRecordingFs(afero.NewOsFs())
+	// The project is not discovered from a parent directory, so that a new
+	// project can be created inside an existing one.
+	root := filepath.Clean(ictx.GetConfiguration().GetString(utils.FlagProjectDir))
+	proj, err := project.FromDir(fsys, root)
 	if err != nil {
 		return nil, err
 	}
-	proj, err := project.FromDir(afero.NewOsFs(), ".")
+	absRoot, err := filepath.Abs(root)
 	if err != nil {
 		return nil, err
 	}
-	defaultName := filepath.Base(wd)
+	defaultName := filepath.Base(absRoot)
 	if name := proj.Manifest().Name; name != "" {
 		defaultName = name
 	}
@@ -52,5 +56,5 @@ func ProjectWorkflow(
 	if err := proj.WriteChanges(); err != nil {
 		return nil, err
 	}
-	return []workflow.Data{}, nil
+	return fsys.data(ictx)
 }
@@ -17,6 +17,7 @@ package init
 import (
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/init/forms"
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
 	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
 	"github.com/spf13/afero"
 )
@@ -26,7 +27,12 @@ func RelationWorkflow(
 	_ []workflow.Data,
 ) ([]workflow.Data, error) {
 	logger := ictx.GetEnhancedLogger()
-	proj, err := project.FromDir(afero.NewOsFs(), ".")
+	fsys := newRecordingFs(afero.NewOsFs())
+	root, err := project.Discover(fsys, ictx.GetConfiguration().GetString(utils.FlagProjectDir))
+	if err != nil {
+		return nil, err
+	}
+	proj, err := project.FromDir(fsys, root)
 	if err != nil {
 		return nil, err
 	}
@@ -40,5 +46,5 @@ func RelationWorkflow(
 	if err := proj.WriteChanges(); err != nil {
 		return nil, err
 	}
-	return []workflow.Data{}, nil
+	return fsys.data(ictx)
 }
@@ -17,6 +17,7 @@ package init
 import (
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/init/forms"
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
 	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
 	"github.com/spf13/afero"
 )
@@ -25,15 +26,23 @@ func RuleWorkflow(
 	ictx workflow.InvocationContext,
 	_ []workflow.Data,
 ) ([]workflow.Data, error) {
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
 	logger := ictx.GetEnhancedLogger()
-	proj, err := project.FromDir(afero.NewOsFs(), ".")
+	fsys := newRecordingFs(afero.NewOsFs())
+	root, err := project.Discover(fsys, ictx.GetConfiguration().GetString(utils.FlagProjectDir))
 	if err != nil {
 		return nil, err
 	}
-	checkProject(proj, logger)
+	proj, err := project.FromDir(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+	checkProject(ctx, proj, logger)
 	form := &forms.RuleForm{
 		Project: proj,
 		Logger:  logger,
+		Context: ctx,
 	}
 	if err := form.Run(); err != nil {
 		return nil, err
@@ -41,5 +50,5 @@ func RuleWorkflow(
 	if err := proj.WriteChanges(); err != nil {
 		return nil, err
 	}
-	return []workflow.Data{}, nil
+	return fsys.data(ictx)
 }
@@ -17,6 +17,7 @@ package init
 import (
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/init/forms"
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
 	"github.com/khulnasoft-lab/go-application-framework/pkg/configuration"
 	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
 	"github.com/khulnasoft/policy-engine/pkg/input/cloudapi"
@@ -27,13 +28,24 @@ func SpecWorkflow(
 	ictx workflow.InvocationContext,
 	_ []workflow.Data,
 ) ([]workflow.Data, error) {
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
 	logger := ictx.GetEnhancedLogger()
-	proj, err := project.FromDir(afero.NewOsFs(), ".")
+	fsys := newRecordingFs(afero.NewOsFs())
+	root, err := project.Discover(fsys, ictx.GetConfiguration().GetString(utils.FlagProjectDir))
 	if err != nil {
 		return nil, err
 	}
-	checkProject(proj, logger)
+	proj, err := project.FromDir(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+	checkProject(ctx, proj, logger)
 	config := ictx.GetConfiguration()
+	timeout, err := utils.GetDuration(config, flagTimeout)
+	if err != nil {
+		return nil, err
+	}
 	client, err := cloudapi.NewClient(cloudapi.ClientConfig{
 		HTTPClient: ictx.GetNetworkAccess().GetHttpClient(),
 		URL:        config.GetString(configuration.API_URL),
@@ -46,6 +58,8 @@ func SpecWorkflow(
 		Client:  client,
 		OrgID:   config.GetString(configuration.ORGANIZATION),
 		Logger:  logger,
+		Context: ctx,
+		Timeout: timeout,
 	}
 	if err := form.Run(); err != nil {
 		return nil, err
@@ -53,5 +67,5 @@ func SpecWorkflow(
 	if err := proj.WriteChanges(); err != nil {
 		return nil, err
 	}
-	return []workflow.Data{}, nil
+	return fsys.data(ictx)
 }
@@ -16,14 +16,19 @@ package init
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/erikgeiser/promptkit/selection"
 	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
 	"github.com/spf13/pflag"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
 )
 
 type TypeChoice string
 
+const flagTimeout = "timeout"
+
 const (
 	TypeProject  = "project"
 	TypeRule     = "rule"
@@ -43,6 +48,8 @@ func TypeChoices() []TypeChoice {
 func RegisterWorkflows(e workflow.Engine) error {
 	workflowID := workflow.NewWorkflowIdentifier("iac.rules.init")
 	flagset := pflag.NewFlagSet("vulnmap-cli-extension-iac-rules-init", pflag.ExitOnError)
+	flagset.Duration(flagTimeout, 2*time.Minute, "Maximum time to wait for the Vulnmap API when creating a spec from a cloud scan")
+	utils.AddProjectDirFlag(flagset)
 	c := workflow.ConfigurationOptionsFromFlagset(flagset)
 	if _, err := e.Register(workflowID, c, initWorkflow); err != nil {
 		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
@@ -0,0 +1,123 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inspect
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/khulnasoft-lab/go-application-framework/pkg/configuration"
+	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/bundle"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
+)
+
+const (
+	flagBundle  = "bundle"
+	flagCompare = "compare"
+)
+
+// dataTypeInspection is the type of the workflow data describing an inspected
+// bundle.
+const dataTypeInspection = "inspection"
+
+// inspection is the outcome of inspecting a bundle. The comparison is only set
+// when the bundle was compared with the project.
+type inspection struct {
+	Summary    *bundle.Summary    `json:"summary"`
+	Comparison *bundle.Comparison `json:"comparison,omitempty"`
+}
+
+func RegisterWorkflows(e workflow.Engine) error {
+	workflowID := workflow.NewWorkflowIdentifier("iac.rules.inspect")
+	flagset := pflag.NewFlagSet("vulnmap-cli-extension-iac-rules-inspect", pflag.ExitOnError)
+
+	flagset.String(flagBundle, "", "Path to the rule bundle tarball. Defaults to the first argument")
+	flagset.Bool(flagCompare, false, "Compare the bundle with the custom rules project")
+	utils.AddProjectDirFlag(flagset)
+
+	c := workflow.ConfigurationOptionsFromFlagset(flagset)
+
+	if _, err := e.Register(workflowID, c, inspectWorkflow); err != nil {
+		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
+	}
+	return nil
+}
+
+func inspectWorkflow(
+	ictx workflow.InvocationContext,
+	_ []workflow.Data,
+) ([]workflow.Data, error) {
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
+	config := ictx.GetConfiguration()
+	path := config.GetString(flagBundle)
+	if path == "" {
+		// The Vulnmap CLI stores the first positional argument here.
+		path = config.GetString(configuration.INPUT_DIRECTORY)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("no bundle to inspect, pass its path with --%s", flagBundle)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	inspected, err := bundle.Read(path, f)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := inspected.Summary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := inspection{Summary: summary}
+	summary.Print(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Bundle is valid.")
+
+	if config.GetBool(flagCompare) {
+		fsys := afero.NewOsFs()
+		root, err := project.Discover(fsys, config.GetString(utils.FlagProjectDir))
+		if err != nil {
+			return nil, err
+		}
+		prj, err := project.FromDir(fsys, root)
+		if err != nil {
+			return nil, err
+		}
+		built, err := bundle.Build(prj)
+		if err != nil {
+			return nil, err
+		}
+		result.Comparison, err = bundle.Compare(ctx, inspected, built)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "\nChanges in the project at %s compared to the bundle:\n", root)
+		result.Comparison.Print(os.Stderr)
+	}
+
+	data, err := utils.NewJSONData(ictx.GetWorkflowIdentifier(), dataTypeInspection, result)
+	if err != nil {
+		return nil, err
+	}
+	return []workflow.Data{data}, nil
+}
@@ -0,0 +1,116 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inspect
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft-lab/go-application-framework/pkg/configuration"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/bundle"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/testutil"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
+)
+
+// writeBundle builds the bundle of a project with the given files and writes
+// its tarball to a temporary file.
+func writeBundle(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, files)
+	prj, err := project.FromDir(afero.NewOsFs(), dir)
+	require.NoError(t, err)
+	built, err := bundle.Build(prj)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	require.NoError(t, os.WriteFile(path, built.TarGz(), 0644))
+	return path
+}
+
+func runInspect(t *testing.T, config configuration.Configuration) (*inspection, error) {
+	data, err := inspectWorkflow(testutil.InvocationContext("iac.rules.inspect", config), nil)
+	if err != nil {
+		return nil, err
+	}
+	require.Len(t, data, 1)
+	result := &inspection{}
+	require.NoError(t, json.Unmarshal(data[0].GetPayload().([]byte), result))
+	return result, nil
+}
+
+func TestInspectWorkflow(t *testing.T) {
+	path := writeBundle(t, testutil.ProjectFiles())
+
+	for _, key := range []string{flagBundle, configuration.INPUT_DIRECTORY} {
+		t.Run(key, func(t *testing.T) {
+			config := configuration.NewInMemory()
+			config.Set(key, path)
+			result, err := runInspect(t, config)
+			require.NoError(t, err)
+			require.NotNil(t, result.Summary)
+			assert.Equal(t, "Test", result.Summary.Name)
+			assert.Equal(t, "v1", result.Summary.BundleFormatVersion)
+			assert.NotEmpty(t, result.Summary.PolicyEngineVersion)
+			assert.NotEmpty(t, result.Summary.ContentHash)
+			assert.Equal(t, []string{"lib/utils.rego"}, result.Summary.Lib)
+			require.Len(t, result.Summary.Rules, 1)
+			assert.Equal(t, "TEST-001", result.Summary.Rules[0].ID)
+			assert.Equal(t, "high", result.Summary.Rules[0].Severity)
+			assert.Nil(t, result.Comparison)
+		})
+	}
+}
+
+func TestInspectWorkflowCompare(t *testing.T) {
+	path := writeBundle(t, testutil.ProjectFiles())
+	dir := t.TempDir()
+	files := testutil.ProjectFiles()
+	files["rules/TEST_001/main.rego"] = strings.ReplaceAll(testutil.Rule, `"high"`, `"low"`)
+	testutil.WriteFiles(t, dir, files)
+
+	config := configuration.NewInMemory()
+	config.Set(flagBundle, path)
+	config.Set(flagCompare, true)
+	config.Set(utils.FlagProjectDir, dir)
+	result, err := runInspect(t, config)
+	require.NoError(t, err)
+	require.NotNil(t, result.Comparison)
+	require.Len(t, result.Comparison.Modified, 1)
+	assert.Equal(t, "TEST-001", result.Comparison.Modified[0].ID)
+	assert.Equal(t, []bundle.MetadataChange{
+		{Field: "severity", Old: "high", New: "low"},
+	}, result.Comparison.Modified[0].Metadata)
+	assert.Empty(t, result.Comparison.Added)
+	assert.Empty(t, result.Comparison.Removed)
+}
+
+func TestInspectWorkflowErrors(t *testing.T) {
+	_, err := runInspect(t, configuration.NewInMemory())
+	assert.EqualError(t, err, "no bundle to inspect, pass its path with --bundle")
+
+	invalid := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a tarball"), 0644))
+	config := configuration.NewInMemory()
+	config.Set(flagBundle, invalid)
+	_, err = runInspect(t, config)
+	assert.Error(t, err)
+}
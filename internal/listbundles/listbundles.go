@@ -0,0 +1,137 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listbundles
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/khulnasoft-lab/go-application-framework/pkg/configuration"
+	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/service"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
+)
+
+const flagTimeout = "timeout"
+
+// dataTypeBundles is the type of the workflow data listing the rule bundles
+// of an organization.
+const dataTypeBundles = "bundles"
+
+// ruleBundle is a rule bundle of the organization. InProject is set for the
+// bundle that the custom rules project was pushed to.
+type ruleBundle struct {
+	service.CustomRules
+	InProject bool `json:"in_project"`
+}
+
+func RegisterWorkflows(e workflow.Engine) error {
+	workflowID := workflow.NewWorkflowIdentifier("iac.rules.list-bundles")
+	flagset := pflag.NewFlagSet("vulnmap-cli-extension-iac-rules-list-bundles", pflag.ExitOnError)
+
+	flagset.Duration(flagTimeout, 2*time.Minute, "Maximum time to wait for the Vulnmap API")
+	utils.AddProjectDirFlag(flagset)
+
+	c := workflow.ConfigurationOptionsFromFlagset(flagset)
+
+	if _, err := e.Register(workflowID, c, listBundlesWorkflow); err != nil {
+		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
+	}
+	return nil
+}
+
+func listBundlesWorkflow(
+	ictx workflow.InvocationContext,
+	_ []workflow.Data,
+) ([]workflow.Data, error) {
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
+	config := ictx.GetConfiguration()
+	orgID := config.GetString(configuration.ORGANIZATION)
+	if orgID == "" {
+		return nil, fmt.Errorf("no organization configured")
+	}
+	timeout, err := utils.GetDuration(config, flagTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	pushedID, err := pushedCustomRulesID(config.GetString(utils.FlagProjectDir), orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	client := service.NewClient(
+		ictx.GetNetworkAccess().GetHttpClient(),
+		config.GetString(configuration.API_URL),
+	)
+	ctx, cancelTimeout := utils.WithTimeout(ctx, timeout)
+	defer cancelTimeout()
+	customRules, err := client.ListCustomRules(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	bundles := make([]ruleBundle, 0, len(customRules))
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tSIZE\t")
+	for _, c := range customRules {
+		b := ruleBundle{
+			CustomRules: c,
+			InProject:   c.ID == pushedID,
+		}
+		bundles = append(bundles, b)
+		marker := ""
+		if b.InProject {
+			marker = "(this project)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", c.ID, c.Created.Format(time.RFC3339), c.Size, marker)
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "%d rule bundles in organization %s.\n", len(bundles), orgID)
+
+	data, err := utils.NewJSONData(ictx.GetWorkflowIdentifier(), dataTypeBundles, bundles)
+	if err != nil {
+		return nil, err
+	}
+	return []workflow.Data{data}, nil
+}
+
+// pushedCustomRulesID returns the ID of the bundle that the custom rules
+// project around dir was pushed to in the given organization. Listing bundles
+// doesn't need a project, so an empty ID is returned when there is none.
+func pushedCustomRulesID(dir string, orgID string) (string, error) {
+	fsys := afero.NewOsFs()
+	root, err := project.Discover(fsys, dir)
+	if err != nil {
+		return "", err
+	}
+	manifest, ok, err := project.ReadManifest(fsys, root)
+	if err != nil || !ok {
+		return "", err
+	}
+	if push := manifest.PushForOrganization(orgID); push != nil {
+		return push.CustomRulesID, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,120 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listbundles
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/go-application-framework/pkg/configuration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/service"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/testutil"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
+)
+
+// newBundlesServer serves the rule bundles of org-a over two pages.
+func newBundlesServer(t *testing.T) *httptest.Server {
+	pages := map[string]string{
+		"": `{
+			"data": [{"id": "a", "type": "rule_bundle", "attributes": {"created": "2023-01-01T00:00:00Z", "size": 100}}],
+			"links": {"next": "/orgs/org-a/cloud/rule_bundles?version=v&starting_after=a"}
+		}`,
+		"a": `{
+			"data": [{"id": "b", "type": "rule_bundle", "attributes": {"created": "2023-01-02T00:00:00Z", "size": 200}}],
+			"links": {}
+		}`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/orgs/org-a/cloud/rule_bundles", r.URL.Path)
+		page, ok := pages[r.URL.Query().Get("starting_after")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, page)
+	}))
+}
+
+func TestListBundlesWorkflow(t *testing.T) {
+	server := newBundlesServer(t)
+	defer server.Close()
+	expected := func(inProject string) []ruleBundle {
+		return []ruleBundle{
+			{
+				CustomRules: service.CustomRules{ID: "a", Created: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Size: 100},
+				InProject:   inProject == "a",
+			},
+			{
+				CustomRules: service.CustomRules{ID: "b", Created: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), Size: 200},
+				InProject:   inProject == "b",
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		name      string
+		files     map[string]string
+		inProject string
+	}{
+		{
+			name: "outside a project",
+		},
+		{
+			name: "project pushed to the organization",
+			files: map[string]string{
+				"manifest.json": `{"name":"Test","push":[{"organization_id":"org-a","custom_rules_id":"b"}]}`,
+				// Listing bundles doesn't load the rules, so a broken rule
+				// doesn't get in the way.
+				"rules/TEST_001/main.rego": "not rego",
+			},
+			inProject: "b",
+		},
+		{
+			name: "project pushed to another organization",
+			files: map[string]string{
+				"manifest.json": `{"name":"Test","push":[{"organization_id":"org-b","custom_rules_id":"a"}]}`,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			testutil.WriteFiles(t, dir, tc.files)
+			config := configuration.NewInMemory()
+			config.Set(configuration.ORGANIZATION, "org-a")
+			config.Set(configuration.API_URL, server.URL)
+			config.Set(utils.FlagProjectDir, dir)
+			data, err := listBundlesWorkflow(testutil.InvocationContext("iac.rules.list-bundles", config), nil)
+			require.NoError(t, err)
+			require.Len(t, data, 1)
+			var bundles []ruleBundle
+			require.NoError(t, json.Unmarshal(data[0].GetPayload().([]byte), &bundles))
+			assert.Equal(t, expected(tc.inProject), bundles)
+		})
+	}
+}
+
+func TestListBundlesWorkflowNoOrganization(t *testing.T) {
+	config := configuration.NewInMemory()
+	config.Set(utils.FlagProjectDir, t.TempDir())
+	_, err := listBundlesWorkflow(testutil.InvocationContext("iac.rules.list-bundles", config), nil)
+	assert.EqualError(t, err, "no organization configured")
+}
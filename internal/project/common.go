@@ -23,6 +23,8 @@ import (
 	"strings"
 
 	"github.com/spf13/afero"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
 )
 
 const directoryPermission = 0755
@@ -34,6 +36,10 @@ var ErrFailedToCreateDir = errors.New("failed to create directory")
 // ErrFailedToCreateFile is returned when we were unable to create a file
 var ErrFailedToCreateFile = errors.New("failed to write to file")
 
+// ErrFailedToRemovePath is returned when we were unable to remove a file or
+// directory.
+var ErrFailedToRemovePath = errors.New("failed to remove path")
+
 // ErrFailedToReadPath is returned when we encountered a filesystem error while
 // reading a path.
 var ErrFailedToReadPath = errors.New("failed to read path")
@@ -64,6 +70,9 @@ type FSNode interface {
 	IsDir() bool
 	// WriteChanges persists any changes to this node back to disk.
 	WriteChanges(fsys afero.Fs) error
+	// Delete stages the removal of this node, which will be persisted when
+	// WriteChanges is called.
+	Delete()
 }
 
 // FSNodeFromFileInfo returns an FSNode for the given fs.FileInfo object in the
@@ -81,6 +90,7 @@ type File struct {
 	path            string
 	exists          bool
 	dirty           bool
+	deleted         bool
 	pendingContents []byte
 }
 
@@ -136,10 +146,29 @@ func (f *File) IsDir() bool {
 func (f *File) UpdateContents(b []byte) {
 	f.pendingContents = b
 	f.dirty = true
+	f.deleted = false
+}
+
+// Delete stages the removal of this file. It will be removed from disk when
+// WriteChanges is called.
+func (f *File) Delete() {
+	f.pendingContents = nil
+	f.dirty = false
+	f.deleted = true
 }
 
 // WriteChanges persists any changes to this file to disk.
 func (f *File) WriteChanges(fsys afero.Fs) error {
+	if f.deleted {
+		if !f.exists {
+			return nil
+		}
+		if err := fsys.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return pathError(f.path, ErrFailedToRemovePath, err)
+		}
+		f.exists = false
+		return nil
+	}
 	if f.exists && !f.dirty {
 		return nil
 	}
@@ -151,7 +180,7 @@ func (f *File) WriteChanges(fsys afero.Fs) error {
 	if err := dir.WriteChanges(fsys); err != nil {
 		return err
 	}
-	if err := afero.WriteFile(fsys, f.path, f.pendingContents, filePermission); err != nil {
+	if err := utils.WriteFileAtomic(fsys, f.path, f.pendingContents, filePermission); err != nil {
 		return pathError(f.path, ErrFailedToCreateFile, err)
 	}
 	f.exists = true
@@ -162,8 +191,9 @@ func (f *File) WriteChanges(fsys afero.Fs) error {
 
 // Dir represents a directory on disk.
 type Dir struct {
-	path   string
-	exists bool
+	path    string
+	exists  bool
+	deleted bool
 }
 
 // NewDir returns a Dir object that represents a directory that does not exist
@@ -215,8 +245,25 @@ func (d *Dir) IsDir() bool {
 	return true
 }
 
-// WriteChanges will create the directory on disk if it does not already exist.
+// Delete stages the removal of this directory and all of its contents. It
+// will be removed from disk when WriteChanges is called.
+func (d *Dir) Delete() {
+	d.deleted = true
+}
+
+// WriteChanges will create the directory on disk if it does not already exist,
+// or remove it if it was deleted.
 func (d *Dir) WriteChanges(fsys afero.Fs) error {
+	if d.deleted {
+		if !d.exists {
+			return nil
+		}
+		if err := fsys.RemoveAll(d.path); err != nil {
+			return pathError(d.path, ErrFailedToRemovePath, err)
+		}
+		d.exists = false
+		return nil
+	}
 	if d.exists {
 		return nil
 	}
@@ -50,6 +50,20 @@ func TestFileWriteChanges(t *testing.T) {
 		assert.NoError(t, err)
 		assert.False(t, stat.IsDir())
 	})
+	t.Run("should replace the file without leaving temporary files", func(t *testing.T) {
+		fsys := afero.NewMemMapFs()
+		assert.NoError(t, afero.WriteFile(fsys, "dir/test", []byte("old"), 0644))
+		file := ExistingFile("dir/test")
+		file.UpdateContents([]byte("new"))
+		err := file.WriteChanges(fsys)
+		assert.NoError(t, err)
+		contents, err := afero.ReadFile(fsys, "dir/test")
+		assert.NoError(t, err)
+		assert.Equal(t, "new", string(contents))
+		entries, err := afero.ReadDir(fsys, "dir")
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
 	t.Run("should produce an error when create file fails", func(t *testing.T) {
 		fsys := afero.NewReadOnlyFs(afero.NewMemMapFs())
 		file := NewFile("test")
@@ -29,6 +29,8 @@ var ErrFailedToParseRegoFile = errors.New("failed to parse rego file")
 type libDir struct {
 	*Dir
 	relations *relationsFile
+	// files contains the other lib files that were added or updated.
+	files map[string]*File
 }
 
 func (l *libDir) WriteChanges(fsys afero.Fs) error {
@@ -38,6 +40,11 @@ func (l *libDir) WriteChanges(fsys afero.Fs) error {
 	if err := l.relations.WriteChanges(fsys); err != nil {
 		return err
 	}
+	for _, f := range l.files {
+		if err := f.WriteChanges(fsys); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -46,6 +53,27 @@ func (l *libDir) addRelation(contents string) (string, error) {
 	return l.relations.addRelation(contents)
 }
 
+// updateModule stages the contents of a rego file in the lib directory,
+// creating it if it doesn't exist yet. The relations file is generated from
+// the module instead.
+func (l *libDir) updateModule(name string, module *ast.Module, contents []byte) (string, error) {
+	path := filepath.Join(l.path, name)
+	if path == l.relations.Path() {
+		l.relations.module = module
+		return path, l.relations.UpdateContents()
+	}
+	if l.files == nil {
+		l.files = map[string]*File{}
+	}
+	file, exists := l.files[name]
+	if !exists {
+		file = NewFile(path)
+		l.files[name] = file
+	}
+	file.UpdateContents(contents)
+	return path, nil
+}
+
 func libFromDir(fsys afero.Fs, root string) (*libDir, error) {
 	path := filepath.Join(root, "lib")
 	dir, err := DirFromPath(fsys, path)
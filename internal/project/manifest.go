@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/afero"
 )
@@ -33,8 +34,9 @@ var ErrFailedToUnmarshalManifest = errors.New("failed to unmarshal manifest")
 
 // Manifest contains metadata about the custom rules project.
 type Manifest struct {
-	Name string         `json:"name"`
-	Push []ManifestPush `json:"push,omitempty"`
+	Name  string         `json:"name"`
+	Push  []ManifestPush `json:"push,omitempty"`
+	Specs *ManifestSpecs `json:"specs,omitempty"`
 }
 
 // ManifestPush contains metadata about where this rule bundle should be pushed
@@ -42,6 +44,52 @@ type Manifest struct {
 type ManifestPush struct {
 	CustomRulesID  string `json:"custom_rules_id,omitempty"`
 	OrganizationID string `json:"organization_id,omitempty"`
+	// ContentHash is the content hash of the rule bundle that was last
+	// pushed, which is used to skip uploads when nothing changed.
+	ContentHash string     `json:"content_hash,omitempty"`
+	PushedAt    *time.Time `json:"pushed_at,omitempty"`
+}
+
+// ManifestSpecs contains settings for how spec output is compared and stored.
+type ManifestSpecs struct {
+	// IgnoreFields lists fields that are removed from spec output before it
+	// is compared or written. Each field is a dot-separated path within a rule
+	// result, e.g. "resources.location". Arrays are traversed implicitly.
+	IgnoreFields []string `json:"ignore_fields,omitempty"`
+}
+
+// PushForOrganization returns the push entry for the given organization, or
+// nil if the rule bundle hasn't been pushed to it.
+func (m Manifest) PushForOrganization(organizationID string) *ManifestPush {
+	for _, push := range m.Push {
+		if push.OrganizationID == organizationID {
+			return &push
+		}
+	}
+	return nil
+}
+
+// SetPush replaces the push entry for the organization of the given entry, or
+// adds it if there is none.
+func (m *Manifest) SetPush(push ManifestPush) {
+	for i, p := range m.Push {
+		if p.OrganizationID == push.OrganizationID {
+			m.Push[i] = push
+			return
+		}
+	}
+	m.Push = append(m.Push, push)
+}
+
+// RemovePush removes the push entry for the given organization.
+func (m *Manifest) RemovePush(organizationID string) {
+	filtered := []ManifestPush{}
+	for _, p := range m.Push {
+		if p.OrganizationID != organizationID {
+			filtered = append(filtered, p)
+		}
+	}
+	m.Push = filtered
 }
 
 // copy creates a copy of the manifest so we don't accidentally modify the
@@ -52,6 +100,14 @@ func (m Manifest) copy() Manifest {
 		cpy.Push = make([]ManifestPush, len(m.Push))
 		copy(cpy.Push, m.Push)
 	}
+	if m.Specs != nil {
+		specs := *m.Specs
+		if m.Specs.IgnoreFields != nil {
+			specs.IgnoreFields = make([]string, len(m.Specs.IgnoreFields))
+			copy(specs.IgnoreFields, m.Specs.IgnoreFields)
+		}
+		cpy.Specs = &specs
+	}
 	return cpy
 }
 
@@ -79,6 +135,16 @@ func (m *manifestFile) UpdateContents(manifest Manifest) {
 	m.manifest = manifest
 }
 
+// ReadManifest reads only the manifest of the project at root, without loading
+// the rest of the project. It returns false when there is no manifest.json.
+func ReadManifest(fsys afero.Fs, root string) (Manifest, bool, error) {
+	m, err := manifestFromDir(fsys, root)
+	if err != nil {
+		return Manifest{}, false, err
+	}
+	return m.manifest, m.Exists(), nil
+}
+
 func manifestFromDir(fsys afero.Fs, root string) (*manifestFile, error) {
 	path := filepath.Join(root, "manifest.json")
 	file, err := FileFromPath(fsys, path)
@@ -28,6 +28,8 @@ func TestManifestFromDir(t *testing.T) {
 	fsys.Mkdir("error", 0755)
 	afero.WriteFile(fsys, "existing/manifest.json", []byte(`{"name": "test"}`), 0644)
 	afero.WriteFile(fsys, "error/manifest.json", []byte(`[]`), 0644)
+	fsys.Mkdir("specs", 0755)
+	afero.WriteFile(fsys, "specs/manifest.json", []byte(`{"name": "test", "specs": {"ignore_fields": ["resources.location"]}}`), 0644)
 
 	testCases := []struct {
 		name          string
@@ -45,6 +47,19 @@ func TestManifestFromDir(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "with spec settings",
+			root: "specs",
+			expected: &manifestFile{
+				File: ExistingFile("specs/manifest.json"),
+				manifest: Manifest{
+					Name: "test",
+					Specs: &ManifestSpecs{
+						IgnoreFields: []string{"resources.location"},
+					},
+				},
+			},
+		},
 		{
 			name: "non-existing manifest file",
 			root: "empty",
@@ -114,3 +129,41 @@ func TestWriteChanges(t *testing.T) {
 		})
 	}
 }
+
+func TestManifestSetPush(t *testing.T) {
+	m := Manifest{
+		Push: []ManifestPush{
+			{OrganizationID: "org1", CustomRulesID: "a"},
+		},
+	}
+	m.SetPush(ManifestPush{OrganizationID: "org2", CustomRulesID: "b"})
+	m.SetPush(ManifestPush{OrganizationID: "org1", CustomRulesID: "a", ContentHash: "hash"})
+	assert.Equal(t, []ManifestPush{
+		{OrganizationID: "org1", CustomRulesID: "a", ContentHash: "hash"},
+		{OrganizationID: "org2", CustomRulesID: "b"},
+	}, m.Push)
+	assert.Equal(t, &ManifestPush{OrganizationID: "org2", CustomRulesID: "b"}, m.PushForOrganization("org2"))
+	assert.Nil(t, m.PushForOrganization("org3"))
+}
+
+func TestReadManifest(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	fsys.Mkdir("empty", 0755)
+	fsys.Mkdir("existing", 0755)
+	fsys.Mkdir("error", 0755)
+	afero.WriteFile(fsys, "existing/manifest.json", []byte(`{"name": "test"}`), 0644)
+	afero.WriteFile(fsys, "error/manifest.json", []byte(`[]`), 0644)
+
+	manifest, ok, err := ReadManifest(fsys, "existing")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Manifest{Name: "test"}, manifest)
+
+	manifest, ok, err = ReadManifest(fsys, "empty")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, Manifest{}, manifest)
+
+	_, _, err = ReadManifest(fsys, "error")
+	assert.ErrorIs(t, err, ErrFailedToUnmarshalManifest)
+}
@@ -16,12 +16,20 @@ package project
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/format"
 	"github.com/khulnasoft/policy-engine/pkg/data"
 	"github.com/khulnasoft/policy-engine/pkg/engine"
+	"github.com/khulnasoft/policy-engine/pkg/models"
+	"github.com/khulnasoft/policy-engine/pkg/policy"
 	"github.com/khulnasoft/policy-engine/pkg/rego"
 	"github.com/spf13/afero"
 )
@@ -73,6 +81,12 @@ func (p *Project) ListRules() []string {
 	return p.rulesDir.ruleDirNames()
 }
 
+// ListRuleSpecDirs lists the rule directories in the project's spec
+// directory.
+func (p *Project) ListRuleSpecDirs() []string {
+	return p.specDir.ruleDirNames()
+}
+
 // AddRule adds a rule to the project. The given rule ID will be transformed to
 // a valid package name and the rego filename will be transformed to fit
 // similar constraints.
@@ -88,6 +102,125 @@ func (p *Project) AddRule(ruleID string, regoFileName string, contents []byte) (
 	return p.rulesDir.addRule(ruleDirName, safeRegoFileName, contents)
 }
 
+// ErrInvalidModulePath is returned when a rego module can't be added to the
+// project at the given path.
+var ErrInvalidModulePath = errors.New("invalid module path")
+
+// UpdateModule stages a rego module at the given path, relative to the project
+// root, creating or replacing the file. Modules can only be added to the rules
+// and lib directories, e.g. rules/TEST_001/main.rego or lib/utils.rego. The
+// module is formatted, so use UpdateModuleSource when its source is known.
+func (p *Project) UpdateModule(path string, module *ast.Module) (string, error) {
+	contents, err := format.Ast(module)
+	if err != nil {
+		return "", err
+	}
+	return p.updateModule(path, module, contents)
+}
+
+// UpdateModuleSource is like UpdateModule, but it writes the given rego source
+// as it is, so that its formatting and comments are kept.
+func (p *Project) UpdateModuleSource(path string, source []byte) (string, error) {
+	module, err := ast.ParseModule(path, string(source))
+	if err != nil {
+		return "", err
+	}
+	return p.updateModule(path, module, source)
+}
+
+func (p *Project) updateModule(path string, module *ast.Module, contents []byte) (string, error) {
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidModulePath, path)
+	}
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+	switch {
+	case len(parts) > 2 && parts[0] == "rules":
+		return p.rulesDir.updateFile(parts[1], filepath.Join(parts[2:]...), contents)
+	case len(parts) > 1 && parts[0] == "lib":
+		return p.libDir.updateModule(filepath.Join(parts[1:]...), module, contents)
+	default:
+		return "", fmt.Errorf("%w: %s is not in a rule directory or the lib directory", ErrInvalidModulePath, path)
+	}
+}
+
+// StaleModules returns the rego files in the rules and lib directories whose
+// paths, relative to the project root, are not in keep, e.g. because they
+// aren't in a rule bundle that is being pulled into the project. Rule
+// directories that would be left without any files are returned instead of
+// their files. The relations library is managed by the project and is never
+// returned. Call Delete and then WriteChanges on the returned nodes to remove
+// them.
+func (p *Project) StaleModules(keep map[string]bool) ([]FSNode, error) {
+	var stale []FSNode
+	ruleDirNames := p.ListRules()
+	sort.Strings(ruleDirNames)
+	for _, name := range ruleDirNames {
+		rule := p.rulesDir.rules[name]
+		if !rule.Exists() {
+			continue
+		}
+		paths, err := p.filesIn(rule.Path())
+		if err != nil {
+			return nil, err
+		}
+		var files []FSNode
+		for _, path := range paths {
+			if isModule(path) && !keep[path] {
+				files = append(files, ExistingFile(filepath.Join(p.Path(), path)))
+			}
+		}
+		if len(files) > 0 && len(files) == len(paths) && !keepsAny(keep, p.relativePath(rule.Path())) {
+			stale = append(stale, ExistingDir(rule.Path()))
+			continue
+		}
+		stale = append(stale, files...)
+	}
+	if p.libDir.Exists() {
+		paths, err := p.filesIn(p.libDir.Path())
+		if err != nil {
+			return nil, err
+		}
+		relations := p.relativePath(p.libDir.relations.Path())
+		for _, path := range paths {
+			if isModule(path) && !keep[path] && path != relations {
+				stale = append(stale, ExistingFile(filepath.Join(p.Path(), path)))
+			}
+		}
+	}
+	return stale, nil
+}
+
+// filesIn returns the paths of all files in the given directory and its
+// subdirectories, relative to the project root.
+func (p *Project) filesIn(dir string) ([]string, error) {
+	var paths []string
+	err := afero.Walk(p.FS, dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return readPathError(path, err)
+		}
+		if !info.IsDir() {
+			paths = append(paths, p.relativePath(path))
+		}
+		return nil
+	})
+	return paths, err
+}
+
+func isModule(path string) bool {
+	return filepath.Ext(path) == ".rego"
+}
+
+// keepsAny returns whether any of the kept paths is inside the given
+// directory.
+func keepsAny(keep map[string]bool, dir string) bool {
+	for path := range keep {
+		if strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 // AddRuleSpec adds a rule to the project. The given rule ID will be transformed
 // to a valid package name and the spec name will be transformed to fit similar
 // constraints.
@@ -103,13 +236,28 @@ func (p *Project) AddRuleSpec(ruleID string, name string, contents []byte) (stri
 	return p.specDir.addRuleSpec(ruleDirName, safeName, contents)
 }
 
-// RuleSpecs returns the rule specs in the project. The returned fixtures can be
-// modified in-place, then the changes can be persisted by calling WriteChanges
-// on the project.
+// RuleSpecs returns the rule specs in the project, sorted by rule directory
+// name and then by spec name. The returned fixtures can be modified in-place,
+// then the changes can be persisted by calling WriteChanges on the project.
 func (p *Project) RuleSpecs() []*RuleSpec {
 	return p.specDir.fixtures()
 }
 
+// RemoveRuleSpec removes the given rule spec from the project, along with its
+// expected output and assertions. The removal can be persisted by calling
+// WriteChanges on the project or on the rule spec.
+func (p *Project) RemoveRuleSpec(spec *RuleSpec) error {
+	return p.specDir.removeRuleSpec(spec)
+}
+
+// StaleExpectedFiles returns the expected output and assertion files in the
+// given rule spec directory that don't have a matching input, e.g. because the
+// input was renamed or deleted. Call Delete and then WriteChanges on the
+// returned files to remove them.
+func (p *Project) StaleExpectedFiles(ruleDirName string) []*File {
+	return p.specDir.staleExpected(ruleDirName)
+}
+
 // AddRelation adds the given relation rule to the relations library for this
 // project.
 func (p *Project) AddRelation(contents string) (string, error) {
@@ -117,8 +265,7 @@ func (p *Project) AddRelation(contents string) (string, error) {
 }
 
 // RelationNames returns the names of all relations defined in the project.
-func (p *Project) RelationNames() ([]string, error) {
-	ctx := context.Background()
+func (p *Project) RelationNames(ctx context.Context) ([]string, error) {
 	eng, err := p.Engine(ctx)
 	if err != nil {
 		return nil, err
@@ -144,15 +291,23 @@ func (p *Project) RelationNames() ([]string, error) {
 
 // RuleMetadata returns a map of rule ID to rule metadata from all rules in the
 // project.
-func (p *Project) RuleMetadata() (map[string]RuleMetadata, error) {
-	ctx := context.Background()
+func (p *Project) RuleMetadata(ctx context.Context) (map[string]RuleMetadata, error) {
 	eng, err := p.Engine(ctx)
 	if err != nil {
 		return nil, err
 	}
+	return EngineRuleMetadata(ctx, eng)
+}
 
+// EngineRuleMetadata returns a map of rule ID to rule metadata from all rules
+// loaded into the given engine.
+func EngineRuleMetadata(ctx context.Context, eng *engine.Engine) (map[string]RuleMetadata, error) {
 	metadata := map[string]RuleMetadata{}
-	for _, r := range eng.Metadata(ctx) {
+	mds, err := eng.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range mds {
 		if r.Error != "" {
 			return nil, fmt.Errorf(r.Error)
 		}
@@ -171,55 +326,92 @@ func (p *Project) RuleMetadata() (map[string]RuleMetadata, error) {
 	return metadata, nil
 }
 
-// InputTypeForRule returns the input for the given rule ID
-func (p *Project) InputTypeForRule(ruleID string) (string, error) {
-	ctx := context.Background()
-	eng, err := p.Engine(ctx)
+// RulePackages returns a map of rule ID to the rego package that defines the
+// rule, e.g. data.rules.TEST_001.
+func RulePackages(ctx context.Context, eng *engine.Engine) (map[string]string, error) {
+	mds, err := eng.Metadata(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-
-	var pkg string
-	for _, r := range eng.Metadata(ctx) {
+	packages := map[string]string{}
+	for _, r := range mds {
 		if r.Error != "" {
 			continue
 		}
-		if r.Metadata.ID != ruleID {
-			continue
-		}
-		pkg = r.Package
+		packages[r.Metadata.ID] = r.Package
 	}
-	if pkg == "" {
+	return packages, nil
+}
+
+// InputTypeForRule returns the input for the given rule ID
+func (p *Project) InputTypeForRule(ctx context.Context, ruleID string) (string, error) {
+	eng, err := p.Engine(ctx)
+	if err != nil {
 		return "", err
 	}
-
-	var inputType string
-	err = eng.Query(ctx, &engine.QueryOptions{
-		Query: fmt.Sprintf("%s.input_type", pkg),
-		ResultProcessor: func(v ast.Value) error {
-			if err := rego.Bind(v, &inputType); err != nil {
-				return err
-			}
-			return nil
-		},
-	})
+	inputTypes, err := RuleInputTypes(ctx, eng)
 	if err != nil {
 		return "", err
 	}
-	return inputType, nil
+	return inputTypes[ruleID], nil
+}
+
+// RuleInputTypes returns a map of rule ID to the input_type declared by that
+// rule. Rules that don't declare an input_type are mapped to an empty string.
+func RuleInputTypes(ctx context.Context, eng *engine.Engine) (map[string]string, error) {
+	mds, err := eng.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The engine requires a resources resolver for every query, even though
+	// input_type doesn't look up any resources.
+	resources := policy.NewResourcesQueryCache(policy.NewInputResolver(&models.State{}))
+	inputTypes := map[string]string{}
+	for _, r := range mds {
+		if r.Error != "" {
+			continue
+		}
+		var inputType string
+		err = eng.Query(ctx, &engine.QueryOptions{
+			Query:          fmt.Sprintf("%s.input_type", r.Package),
+			ResourcesQuery: resources,
+			ResultProcessor: func(v ast.Value) error {
+				return rego.Bind(v, &inputType)
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		inputTypes[r.Metadata.ID] = inputType
+	}
+	return inputTypes, nil
 }
 
 func (p *Project) Providers() (providers []data.Provider) {
-	fsys := afero.NewIOFS(p.FS)
+	// Modules are named relative to the project root, so that their names
+	// don't depend on the directory the project was loaded from.
+	root := p.FS
+	if filepath.Clean(p.Path()) != "." {
+		root = afero.NewBasePathFs(p.FS, p.Path())
+	}
+	fsys := afero.NewIOFS(root)
 	if p.libDir.Exists() {
-		providers = append(providers, data.FSProvider(fsys, p.libDir.Path()))
+		providers = append(providers, data.FSProvider(fsys, p.relativePath(p.libDir.Path())))
 	}
 	if p.rulesDir.Exists() {
-		providers = append(providers, data.FSProvider(fsys, p.rulesDir.Path()))
+		providers = append(providers, data.FSProvider(fsys, p.relativePath(p.rulesDir.Path())))
 	}
 	return
 }
 
+func (p *Project) relativePath(path string) string {
+	rel, err := filepath.Rel(p.Path(), path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
 func (p *Project) Engine(ctx context.Context) (*engine.Engine, error) {
 	eng := engine.NewEngine(ctx, &engine.EngineOptions{
 		Providers: p.Providers(),
@@ -265,3 +457,28 @@ func FromDir(fsys afero.Fs, root string) (*Project, error) {
 	}
 	return p, nil
 }
+
+// Discover returns the root of the project that contains dir, which is the
+// closest directory, starting at dir and walking up its parents, that has a
+// manifest.json file. If there is none, dir itself is returned so that a new
+// project can be created there. The returned path is relative when dir is.
+func Discover(fsys afero.Fs, dir string) (string, error) {
+	current := filepath.Clean(dir)
+	for {
+		_, err := fsys.Stat(filepath.Join(current, "manifest.json"))
+		if err == nil {
+			return current, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", readPathError(current, err)
+		}
+		abs, err := filepath.Abs(current)
+		if err != nil {
+			return "", err
+		}
+		if filepath.Dir(abs) == abs {
+			return filepath.Clean(dir), nil
+		}
+		current = filepath.Join(current, "..")
+	}
+}
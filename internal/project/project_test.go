@@ -15,10 +15,13 @@
 package project
 
 import (
+	"context"
 	"testing"
 
+	"github.com/open-policy-agent/opa/ast"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var testRelationsFile = []byte(`package relations
@@ -122,10 +125,10 @@ func TestProjectFromDir(t *testing.T) {
 			assert.Equal(t, tc.expectedManifest, p.Manifest())
 			assert.Equal(t, tc.expectedRules, p.ListRules())
 			assert.Equal(t, tc.expectedRuleSpecs, p.RuleSpecs())
-			relations, err := p.RelationNames()
+			relations, err := p.RelationNames(context.Background())
 			assert.NoError(t, err)
 			assert.Equal(t, tc.expectedRelations, relations)
-			metadata, err := p.RuleMetadata()
+			metadata, err := p.RuleMetadata(context.Background())
 			assert.NoError(t, err)
 			assert.Equal(t, tc.expectedMetadata, metadata)
 		})
@@ -242,7 +245,7 @@ func TestProjectWriteChanges(t *testing.T) {
 		fsys := afero.NewMemMapFs()
 		p, err := FromDir(fsys, "new")
 		assert.NoError(t, err)
-		relations, err := p.RelationNames()
+		relations, err := p.RelationNames(context.Background())
 		assert.NoError(t, err)
 		assert.Empty(t, relations)
 
@@ -262,8 +265,148 @@ func TestProjectWriteChanges(t *testing.T) {
 		// Re-read the project from disk and assert that the new rule is listed
 		updated, err := FromDir(fsys, "new")
 		assert.NoError(t, err)
-		relations, err = updated.RelationNames()
+		relations, err = updated.RelationNames(context.Background())
 		assert.NoError(t, err)
 		assert.Equal(t, []string{"aws_s3_bucket.logging"}, relations)
 	})
 }
+
+func TestDiscover(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	fsys.MkdirAll("existing/rules/TEST_001", 0755)
+	fsys.MkdirAll("empty/nested", 0755)
+	afero.WriteFile(fsys, "existing/manifest.json", []byte(`{"name":"Test"}`), 0644)
+	testCases := []struct {
+		name     string
+		dir      string
+		expected string
+	}{
+		{
+			name:     "project root",
+			dir:      "existing",
+			expected: "existing",
+		},
+		{
+			name:     "nested directory",
+			dir:      "existing/rules/TEST_001",
+			expected: "existing",
+		},
+		{
+			name:     "no manifest",
+			dir:      "empty/nested",
+			expected: "empty/nested",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root, err := Discover(fsys, tc.dir)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, root)
+		})
+	}
+}
+
+func TestProjectUpdateModule(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	p, err := FromDir(fsys, "prj")
+	assert.NoError(t, err)
+	rule := ast.MustParseModule(string(testRule))
+	lib := ast.MustParseModule("package lib.utils\n\nis_bucket(name) { contains(name, \"bucket\") }\n")
+	relations := ast.MustParseModule("package relations\n\nrelations := []\n")
+
+	path, err := p.UpdateModule("rules/TEST_001/main.rego", rule)
+	assert.NoError(t, err)
+	assert.Equal(t, "prj/rules/TEST_001/main.rego", path)
+	path, err = p.UpdateModule("lib/utils.rego", lib)
+	assert.NoError(t, err)
+	assert.Equal(t, "prj/lib/utils.rego", path)
+	_, err = p.UpdateModule("lib/relations.rego", relations)
+	assert.NoError(t, err)
+	for _, invalid := range []string{"main.rego", "rules/main.rego", "spec/main.rego", "../lib/utils.rego"} {
+		_, err = p.UpdateModule(invalid, lib)
+		assert.ErrorIs(t, err, ErrInvalidModulePath, invalid)
+	}
+	assert.NoError(t, p.WriteChanges())
+
+	for path, expected := range map[string]*ast.Module{
+		"prj/rules/TEST_001/main.rego": rule,
+		"prj/lib/utils.rego":           lib,
+		"prj/lib/relations.rego":       relations,
+	} {
+		contents, err := afero.ReadFile(fsys, path)
+		assert.NoError(t, err)
+		actual, err := ast.ParseModule(path, string(contents))
+		assert.NoError(t, err)
+		assert.True(t, expected.Equal(actual), path)
+	}
+	assert.Equal(t, []string{"TEST_001"}, p.ListRules())
+}
+
+func TestProjectUpdateModuleSource(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	p, err := FromDir(fsys, "prj")
+	assert.NoError(t, err)
+	source := []byte("package lib.utils\n\n# Hand-formatted, with a comment.\nis_bucket(name) { contains(name, \"bucket\") }\n")
+
+	path, err := p.UpdateModuleSource("lib/utils.rego", source)
+	assert.NoError(t, err)
+	assert.Equal(t, "prj/lib/utils.rego", path)
+	_, err = p.UpdateModuleSource("rules/TEST_001/main.rego", []byte("not rego"))
+	assert.Error(t, err)
+	_, err = p.UpdateModuleSource("spec/main.rego", source)
+	assert.ErrorIs(t, err, ErrInvalidModulePath)
+	assert.NoError(t, p.WriteChanges())
+
+	contents, err := afero.ReadFile(fsys, "prj/lib/utils.rego")
+	assert.NoError(t, err)
+	assert.Equal(t, string(source), string(contents))
+}
+
+func TestProjectStaleModules(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	for _, path := range []string{
+		"prj/rules/TEST_001/main.rego",
+		"prj/rules/TEST_001/old.rego",
+		"prj/rules/TEST_001/README.md",
+		"prj/rules/TEST_002/main.rego",
+		"prj/rules/TEST_002/main_test.rego",
+		"prj/rules/TEST_003/main.rego",
+		"prj/lib/utils.rego",
+		"prj/lib/old.rego",
+		"prj/lib/data.json",
+	} {
+		assert.NoError(t, afero.WriteFile(fsys, path, []byte{}, 0644))
+	}
+	assert.NoError(t, afero.WriteFile(fsys, "prj/manifest.json", []byte(`{"name":"Test"}`), 0644))
+	assert.NoError(t, afero.WriteFile(fsys, "prj/lib/relations.rego", []byte(relationsStub), 0644))
+	p, err := FromDir(fsys, "prj")
+	require.NoError(t, err)
+
+	stale, err := p.StaleModules(map[string]bool{
+		"rules/TEST_001/main.rego": true,
+		"rules/TEST_003/new.rego":  true,
+		"lib/utils.rego":           true,
+	})
+	assert.NoError(t, err)
+	var paths []string
+	for _, node := range stale {
+		paths = append(paths, node.Path())
+	}
+	assert.ElementsMatch(t, []string{
+		"prj/rules/TEST_001/old.rego",
+		"prj/rules/TEST_002",
+		"prj/rules/TEST_003/main.rego",
+		"prj/lib/old.rego",
+	}, paths)
+
+	for _, node := range stale {
+		node.Delete()
+		assert.NoError(t, node.WriteChanges(fsys))
+	}
+	exists, err := afero.DirExists(fsys, "prj/rules/TEST_002")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = afero.Exists(fsys, "prj/rules/TEST_001/README.md")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
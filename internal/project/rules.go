@@ -52,6 +52,20 @@ func (r *rulesDir) addRule(ruleDirName string, regoFileName string, contents []b
 	return r.rules[ruleDirName].files[regoFileName].Path(), nil
 }
 
+// updateFile stages the contents of a file in the given rule directory,
+// creating the directory and file if they don't exist yet.
+func (r *rulesDir) updateFile(ruleDirName string, name string, contents []byte) (string, error) {
+	rule, exists := r.rules[ruleDirName]
+	if !exists {
+		rule = &ruleDir{
+			Dir:   NewDir(filepath.Join(r.path, ruleDirName)),
+			files: map[string]FSNode{},
+		}
+		r.rules[ruleDirName] = rule
+	}
+	return rule.updateFile(name, contents)
+}
+
 func (r *rulesDir) ruleDirNames() []string {
 	var names []string
 	for n := range r.rules {
@@ -113,6 +127,21 @@ func (r *ruleDir) WriteChanges(fsys afero.Fs) error {
 	return nil
 }
 
+func (r *ruleDir) updateFile(name string, contents []byte) (string, error) {
+	path := filepath.Join(r.path, name)
+	node, exists := r.files[name]
+	if !exists {
+		node = NewFile(path)
+		r.files[name] = node
+	}
+	file, ok := node.(*File)
+	if !ok {
+		return "", fmt.Errorf("%w: %s is a directory", ErrUnexpectedType, path)
+	}
+	file.UpdateContents(contents)
+	return path, nil
+}
+
 func ruleFromDir(fsys afero.Fs, parent string, name string) (*ruleDir, error) {
 	path := filepath.Join(parent, name)
 	entries, err := afero.ReadDir(fsys, path)
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io/fs"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/spf13/afero"
@@ -26,13 +27,19 @@ import (
 
 var ErrRuleSpecAlreadyExists = errors.New("rule spec already exists")
 
+// ErrRuleSpecNotFound is returned when removing a rule spec that is not part of
+// the project.
+var ErrRuleSpecNotFound = errors.New("rule spec not found")
+
 // RuleSpec represents an input file or directory and an expected output
-// file.
+// file. A spec can also have an assertions file, which contains partial
+// assertions about the output instead of a full snapshot.
 type RuleSpec struct {
 	name        string
 	RuleDirName string
 	Input       FSNode
 	Expected    *File
+	Assertions  *File
 }
 
 // WriteChanges persists any changes to this fixture to disk.
@@ -45,9 +52,26 @@ func (f *RuleSpec) WriteChanges(fsys afero.Fs) error {
 			return err
 		}
 	}
+	if f.Assertions != nil {
+		if err := f.Assertions.WriteChanges(fsys); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// Delete stages the removal of this fixture's input, expected output and
+// assertions. They will be removed from disk when WriteChanges is called.
+func (f *RuleSpec) Delete() {
+	f.Input.Delete()
+	if f.Expected != nil {
+		f.Expected.Delete()
+	}
+	if f.Assertions != nil {
+		f.Assertions.Delete()
+	}
+}
+
 // UpdateExpected updates the expected output file for this fixture.
 func (f *RuleSpec) UpdateExpected(contents []byte) {
 	if f.Expected == nil {
@@ -56,13 +80,35 @@ func (f *RuleSpec) UpdateExpected(contents []byte) {
 	f.Expected.UpdateContents(contents)
 }
 
+// Name returns the name of this fixture, which is the file or directory name
+// of its input.
+func (f *RuleSpec) Name() string {
+	return f.name
+}
+
 func (f *RuleSpec) ExpectedPath() string {
+	return f.expectedDirPath("json")
+}
+
+// AssertionsPath returns the path of the assertions file for this fixture,
+// which lives next to the expected output file.
+func (f *RuleSpec) AssertionsPath() string {
+	return f.expectedDirPath("assert.yaml")
+}
+
+func (f *RuleSpec) expectedDirPath(ext string) string {
 	noExt := strings.TrimSuffix(f.name, filepath.Ext(f.name))
 	parent := filepath.Dir(f.Input.Path())
-	expectedName := fmt.Sprintf("%s.json", noExt)
+	expectedName := fmt.Sprintf("%s.%s", noExt, ext)
 	return filepath.Join(parent, "..", "expected", expectedName)
 }
 
+// isExpectedFile returns whether the given file name is an expected output or
+// assertions file name.
+func isExpectedFile(name string) bool {
+	return strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".assert.yaml")
+}
+
 func ruleSpecFromFileInfo(fsys afero.Fs, parent string, info fs.FileInfo, ruleDirName string) (*RuleSpec, error) {
 	fixture := &RuleSpec{
 		name:        info.Name(),
@@ -79,6 +125,13 @@ func ruleSpecFromFileInfo(fsys afero.Fs, parent string, info fs.FileInfo, ruleDi
 		// create empty JSON files.
 		fixture.Expected = expectedFile
 	}
+	assertionsFile, err := FileFromPath(fsys, fixture.AssertionsPath())
+	if err != nil {
+		return nil, err
+	}
+	if assertionsFile.Exists() {
+		fixture.Assertions = assertionsFile
+	}
 	return fixture, nil
 }
 
@@ -99,6 +152,8 @@ func (t *specDir) WriteChanges(fsys afero.Fs) error {
 	return nil
 }
 
+// fixtures returns all fixtures sorted by rule directory name and then by
+// fixture name.
 func (t *specDir) fixtures() []*RuleSpec {
 	var fixtures []*RuleSpec
 	for _, r := range t.ruleSpecs {
@@ -106,9 +161,40 @@ func (t *specDir) fixtures() []*RuleSpec {
 			fixtures = append(fixtures, f)
 		}
 	}
+	sort.Slice(fixtures, func(i, j int) bool {
+		if fixtures[i].RuleDirName != fixtures[j].RuleDirName {
+			return fixtures[i].RuleDirName < fixtures[j].RuleDirName
+		}
+		return fixtures[i].name < fixtures[j].name
+	})
 	return fixtures
 }
 
+func (t *specDir) ruleDirNames() []string {
+	var names []string
+	for n := range t.ruleSpecs {
+		names = append(names, n)
+	}
+	return names
+}
+
+func (t *specDir) removeRuleSpec(spec *RuleSpec) error {
+	for _, rt := range t.ruleSpecs {
+		if rt.removeFixture(spec) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrRuleSpecNotFound, spec.Input.Path())
+}
+
+func (t *specDir) staleExpected(ruleDirName string) []*File {
+	rt, ok := t.ruleSpecs[ruleDirName]
+	if !ok {
+		return nil
+	}
+	return rt.staleExpected
+}
+
 func (t *specDir) addRuleSpecsDir(ruleDirName string) *ruleSpecsDir {
 	t.ruleSpecs[ruleDirName] = &ruleSpecsDir{
 		Dir:      NewDir(filepath.Join(t.Path(), "rules", ruleDirName)),
@@ -176,6 +262,12 @@ func specFromDir(fsys afero.Fs, root string) (*specDir, error) {
 type ruleSpecsDir struct {
 	*Dir
 	fixtures map[string]*RuleSpec
+	// removed contains fixtures that have been removed but whose removal
+	// hasn't been written to disk yet.
+	removed []*RuleSpec
+	// staleExpected contains expected output and assertion files that don't
+	// have a matching input, sorted by path.
+	staleExpected []*File
 }
 
 func (t *ruleSpecsDir) WriteChanges(fsys afero.Fs) error {
@@ -188,9 +280,32 @@ func (t *ruleSpecsDir) WriteChanges(fsys afero.Fs) error {
 			return err
 		}
 	}
+	for _, f := range t.removed {
+		if err := f.WriteChanges(fsys); err != nil {
+			return err
+		}
+	}
+	t.removed = nil
+	for _, f := range t.staleExpected {
+		if err := f.WriteChanges(fsys); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func (t *ruleSpecsDir) removeFixture(spec *RuleSpec) bool {
+	for name, f := range t.fixtures {
+		if f == spec {
+			delete(t.fixtures, name)
+			spec.Delete()
+			t.removed = append(t.removed, spec)
+			return true
+		}
+	}
+	return false
+}
+
 func (t *ruleSpecsDir) addFixture(name string, contents []byte) (string, error) {
 	f, exists := t.fixtures[name]
 	if exists {
@@ -212,10 +327,18 @@ func ruleSpecsFromDir(fsys afero.Fs, parent string, name string) (*ruleSpecsDir,
 		return nil, readPathError(path, err)
 	}
 	fixtures := map[string]*RuleSpec{}
+	var expectedEntries []fs.FileInfo
 	for _, e := range entries {
 		if !e.IsDir() {
 			continue
 		}
+		if e.Name() == "expected" {
+			expectedDir := filepath.Join(path, e.Name())
+			expectedEntries, err = afero.ReadDir(fsys, expectedDir)
+			if err != nil {
+				return nil, readPathError(expectedDir, err)
+			}
+		}
 		if e.Name() == "inputs" {
 			inputsDir := filepath.Join(path, e.Name())
 			entries, err := afero.ReadDir(fsys, inputsDir)
@@ -232,8 +355,29 @@ func ruleSpecsFromDir(fsys afero.Fs, parent string, name string) (*ruleSpecsDir,
 		}
 	}
 	t := &ruleSpecsDir{
-		Dir:      ExistingDir(path),
-		fixtures: fixtures,
+		Dir:           ExistingDir(path),
+		fixtures:      fixtures,
+		staleExpected: findStaleExpected(filepath.Join(path, "expected"), expectedEntries, fixtures),
 	}
 	return t, nil
 }
+
+// findStaleExpected returns the expected output and assertion files in the
+// expected directory that don't belong to any of the given fixtures. Other
+// files, such as a .gitkeep or a README, are left alone. Entries are sorted
+// by name, so the result is sorted by path.
+func findStaleExpected(expectedDir string, entries []fs.FileInfo, fixtures map[string]*RuleSpec) []*File {
+	known := map[string]bool{}
+	for _, f := range fixtures {
+		known[filepath.Base(f.ExpectedPath())] = true
+		known[filepath.Base(f.AssertionsPath())] = true
+	}
+	var stale []*File
+	for _, e := range entries {
+		if e.IsDir() || known[e.Name()] || !isExpectedFile(e.Name()) {
+			continue
+		}
+		stale = append(stale, ExistingFile(filepath.Join(expectedDir, e.Name())))
+	}
+	return stale
+}
@@ -32,6 +32,8 @@ func TestSpecFromDir(t *testing.T) {
 	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/inputs/invalid_ec2/module.tf", []byte{}, 0644)
 	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/expected/infra.json", []byte{}, 0644)
 	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/expected/invalid_ec2.json", []byte{}, 0644)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/inputs/partial.tf", []byte{}, 0644)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/expected/partial.assert.yaml", []byte{}, 0644)
 	afero.WriteFile(fsys, "existing/spec/rules/ignored.txt", []byte{}, 0644)
 	testCases := []struct {
 		name     string
@@ -72,6 +74,12 @@ func TestSpecFromDir(t *testing.T) {
 								Input:       ExistingDir("existing/spec/rules/TEST_001/inputs/invalid_ec2"),
 								Expected:    ExistingFile("existing/spec/rules/TEST_001/expected/invalid_ec2.json"),
 							},
+							"partial.tf": {
+								name:        "partial.tf",
+								RuleDirName: "TEST_001",
+								Input:       ExistingFile("existing/spec/rules/TEST_001/inputs/partial.tf"),
+								Assertions:  ExistingFile("existing/spec/rules/TEST_001/expected/partial.assert.yaml"),
+							},
 						},
 					},
 				},
@@ -176,3 +184,89 @@ func TestSpecDirWriteChanges(t *testing.T) {
 		})
 	}
 }
+
+func TestSpecDirFixturesOrder(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	fsys.MkdirAll("existing/spec/rules/TEST_002/inputs", 0755)
+	fsys.MkdirAll("existing/spec/rules/TEST_001/inputs", 0755)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_002/inputs/b.tf", []byte{}, 0644)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_002/inputs/a.tf", []byte{}, 0644)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/inputs/c.tf", []byte{}, 0644)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/inputs/a.tf", []byte{}, 0644)
+	td, err := specFromDir(fsys, "existing")
+	assert.NoError(t, err)
+	var paths []string
+	for _, f := range td.fixtures() {
+		paths = append(paths, f.Input.Path())
+	}
+	assert.Equal(t, []string{
+		"existing/spec/rules/TEST_001/inputs/a.tf",
+		"existing/spec/rules/TEST_001/inputs/c.tf",
+		"existing/spec/rules/TEST_002/inputs/a.tf",
+		"existing/spec/rules/TEST_002/inputs/b.tf",
+	}, paths)
+}
+
+func TestSpecDirStaleExpected(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	fsys.MkdirAll("existing/spec/rules/TEST_001/inputs", 0755)
+	fsys.MkdirAll("existing/spec/rules/TEST_001/expected", 0755)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/inputs/infra.tf", []byte{}, 0644)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/expected/infra.json", []byte{}, 0644)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/expected/renamed.json", []byte{}, 0644)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/expected/deleted.assert.yaml", []byte{}, 0644)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/expected/.gitkeep", []byte{}, 0644)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/expected/README.md", []byte{}, 0644)
+	td, err := specFromDir(fsys, "existing")
+	assert.NoError(t, err)
+	stale := td.staleExpected("TEST_001")
+	assert.Equal(t, []*File{
+		ExistingFile("existing/spec/rules/TEST_001/expected/deleted.assert.yaml"),
+		ExistingFile("existing/spec/rules/TEST_001/expected/renamed.json"),
+	}, stale)
+	assert.Nil(t, td.staleExpected("TEST_002"))
+
+	for _, f := range stale {
+		f.Delete()
+	}
+	assert.NoError(t, td.WriteChanges(fsys))
+	for _, path := range []string{
+		"existing/spec/rules/TEST_001/expected/deleted.assert.yaml",
+		"existing/spec/rules/TEST_001/expected/renamed.json",
+	} {
+		exists, _ := afero.Exists(fsys, path)
+		assert.False(t, exists, path)
+	}
+	for _, path := range []string{
+		"existing/spec/rules/TEST_001/expected/infra.json",
+		"existing/spec/rules/TEST_001/expected/.gitkeep",
+		"existing/spec/rules/TEST_001/expected/README.md",
+	} {
+		exists, _ := afero.Exists(fsys, path)
+		assert.True(t, exists, path)
+	}
+}
+
+func TestSpecDirRemoveRuleSpec(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	fsys.MkdirAll("existing/spec/rules/TEST_001/inputs/module", 0755)
+	fsys.MkdirAll("existing/spec/rules/TEST_001/expected", 0755)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/inputs/module/main.tf", []byte{}, 0644)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/expected/module.json", []byte{}, 0644)
+	afero.WriteFile(fsys, "existing/spec/rules/TEST_001/inputs/infra.tf", []byte{}, 0644)
+	td, err := specFromDir(fsys, "existing")
+	assert.NoError(t, err)
+	fixtures := td.fixtures()
+	assert.Len(t, fixtures, 2)
+	assert.NoError(t, td.removeRuleSpec(fixtures[1]))
+	assert.ErrorIs(t, td.removeRuleSpec(fixtures[1]), ErrRuleSpecNotFound)
+	assert.NoError(t, td.WriteChanges(fsys))
+	assert.Len(t, td.fixtures(), 1)
+	for _, path := range []string{
+		"existing/spec/rules/TEST_001/inputs/module",
+		"existing/spec/rules/TEST_001/expected/module.json",
+	} {
+		exists, _ := afero.Exists(fsys, path)
+		assert.False(t, exists, path)
+	}
+}
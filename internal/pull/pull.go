@@ -0,0 +1,210 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pull
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/khulnasoft-lab/go-application-framework/pkg/configuration"
+	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/bundle"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/service"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
+)
+
+const (
+	flagCustomRulesID = "custom-rules-id"
+	flagOutput        = "output"
+	flagForce         = "force"
+	flagTimeout       = "timeout"
+)
+
+// dataTypeResult is the type of the workflow data describing the outcome of a
+// pull.
+const dataTypeResult = "result"
+
+// pullResult describes the rule bundle that was downloaded and where it was
+// written to. Output is only set when the tarball was written as-is, and Files
+// only when it was unpacked into the project. Removed lists the rule
+// directories and lib files that were removed because they aren't in the
+// bundle.
+type pullResult struct {
+	OrganizationID string   `json:"organization_id"`
+	CustomRulesID  string   `json:"custom_rules_id"`
+	Output         string   `json:"output,omitempty"`
+	Files          []string `json:"files,omitempty"`
+	Removed        []string `json:"removed,omitempty"`
+}
+
+func RegisterWorkflows(e workflow.Engine) error {
+	workflowID := workflow.NewWorkflowIdentifier("iac.rules.pull")
+	flagset := pflag.NewFlagSet("vulnmap-cli-extension-iac-rules-pull", pflag.ExitOnError)
+
+	flagset.String(flagCustomRulesID, "", "ID of the rule bundle to download. Defaults to the bundle pushed to the organization in manifest.json")
+	flagset.String(flagOutput, "", "Write the rule bundle tarball to this path instead of unpacking it into the project")
+	flagset.Bool(flagForce, false, "Replace the rules and lib files of a project that already contains rules, removing the ones that aren't in the bundle")
+	flagset.Duration(flagTimeout, 2*time.Minute, "Maximum time to wait for the Vulnmap API")
+	utils.AddProjectDirFlag(flagset)
+
+	c := workflow.ConfigurationOptionsFromFlagset(flagset)
+
+	if _, err := e.Register(workflowID, c, pullWorkflow); err != nil {
+		return fmt.Errorf("error while registering %s workflow: %w", workflowID, err)
+	}
+	return nil
+}
+
+func pullWorkflow(
+	ictx workflow.InvocationContext,
+	_ []workflow.Data,
+) ([]workflow.Data, error) {
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
+	logger := ictx.GetLogger()
+	config := ictx.GetConfiguration()
+	orgID := config.GetString(configuration.ORGANIZATION)
+	output := config.GetString(flagOutput)
+	timeout, err := utils.GetDuration(config, flagTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if orgID == "" {
+		return nil, fmt.Errorf("no organization configured")
+	}
+
+	fsys := afero.NewOsFs()
+	root, err := project.Discover(fsys, config.GetString(utils.FlagProjectDir))
+	if err != nil {
+		return nil, err
+	}
+	prj, err := project.FromDir(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+	manifest := prj.Manifest()
+	customRulesID := config.GetString(flagCustomRulesID)
+	if customRulesID == "" {
+		push := manifest.PushForOrganization(orgID)
+		if push == nil {
+			return nil, fmt.Errorf("no rule bundle pushed to organization %s in manifest.json, pass its ID with --%s", orgID, flagCustomRulesID)
+		}
+		customRulesID = push.CustomRulesID
+	}
+	force := config.GetBool(flagForce)
+	if output == "" && len(prj.ListRules()) > 0 && !force {
+		return nil, fmt.Errorf("project at %s already contains rules, use --%s to overwrite them", root, flagForce)
+	}
+
+	client := service.NewClient(
+		ictx.GetNetworkAccess().GetHttpClient(),
+		config.GetString(configuration.API_URL),
+	)
+	ctx, cancelTimeout := utils.WithTimeout(ctx, timeout)
+	defer cancelTimeout()
+	logger.Println("downloading custom rules bundle", customRulesID)
+	targz, err := client.GetCustomRules(ctx, orgID, customRulesID)
+	if err != nil {
+		return nil, err
+	}
+	pulled, err := bundle.Read(customRulesID, bytes.NewReader(targz))
+	if err != nil {
+		return nil, err
+	}
+	result := pullResult{
+		OrganizationID: orgID,
+		CustomRulesID:  customRulesID,
+	}
+
+	if output != "" {
+		if err := utils.WriteFileAtomic(fsys, output, targz, 0644); err != nil {
+			return nil, err
+		}
+		result.Output = output
+		fmt.Fprintf(os.Stderr, "Wrote rule bundle %s to %s.\n", customRulesID, output)
+	} else {
+		modules := pulled.Modules()
+		paths := make([]string, 0, len(modules))
+		for path := range modules {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		// Stale files are looked up before the bundle's files are staged, so
+		// that the project ends up matching the bundle.
+		var stale []project.FSNode
+		if force {
+			keep := map[string]bool{}
+			for _, path := range paths {
+				keep[path] = true
+			}
+			stale, err = prj.StaleModules(keep)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, path := range paths {
+			// Modules are written as they are in the bundle, and only
+			// formatted when their source isn't available.
+			var written string
+			if source, ok := pulled.Source(path); ok {
+				written, err = prj.UpdateModuleSource(path, source)
+			} else {
+				written, err = prj.UpdateModule(path, modules[path])
+			}
+			if err != nil {
+				return nil, err
+			}
+			result.Files = append(result.Files, written)
+		}
+		if manifest.Name == "" {
+			manifest.Name = pulled.Name()
+		}
+		if manifest.PushForOrganization(orgID) == nil {
+			manifest.Push = append(manifest.Push, project.ManifestPush{
+				CustomRulesID:  customRulesID,
+				OrganizationID: orgID,
+			})
+		}
+		prj.UpdateManifest(manifest)
+		if err := prj.WriteChanges(); err != nil {
+			return nil, err
+		}
+		for _, node := range stale {
+			node.Delete()
+			if err := node.WriteChanges(fsys); err != nil {
+				return nil, err
+			}
+			result.Removed = append(result.Removed, node.Path())
+			fmt.Fprintf(os.Stderr, "Removed %s, which is not in the rule bundle.\n", node.Path())
+		}
+		if pulled.HasData() {
+			fmt.Fprintln(os.Stderr, "The rule bundle contains data documents, which were not restored.")
+		}
+		fmt.Fprintf(os.Stderr, "Pulled rule bundle %s into project %q.\n", customRulesID, root)
+	}
+
+	data, err := utils.NewJSONData(ictx.GetWorkflowIdentifier(), dataTypeResult, result)
+	if err != nil {
+		return nil, err
+	}
+	return []workflow.Data{data}, nil
+}
@@ -0,0 +1,134 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pull
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/khulnasoft-lab/go-application-framework/pkg/configuration"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/bundle"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/testutil"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
+)
+
+const (
+	testOrgID         = "11111111-2222-3333-4444-555555555555"
+	testCustomRulesID = "bundle"
+)
+
+// testBundle returns the tarball of a rule bundle with a single rule and lib
+// file.
+func testBundle(t *testing.T) []byte {
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, testutil.ProjectFiles())
+	prj, err := project.FromDir(afero.NewOsFs(), dir)
+	require.NoError(t, err)
+	built, err := bundle.Build(prj)
+	require.NoError(t, err)
+	return built.TarGz()
+}
+
+func runPull(t *testing.T, dir string, force bool) (*pullResult, error) {
+	targz := testBundle(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/orgs/"+testOrgID+"/cloud/rule_bundles/"+testCustomRulesID, r.URL.Path)
+		w.Write(targz)
+	}))
+	defer server.Close()
+
+	config := configuration.NewInMemory()
+	config.Set(configuration.ORGANIZATION, testOrgID)
+	config.Set(configuration.API_URL, server.URL)
+	config.Set(utils.FlagProjectDir, dir)
+	config.Set(flagCustomRulesID, testCustomRulesID)
+	config.Set(flagForce, force)
+	ictx := testutil.InvocationContext("iac.rules.pull", config)
+	data, err := pullWorkflow(ictx, nil)
+	if err != nil {
+		return nil, err
+	}
+	require.Len(t, data, 1)
+	result := &pullResult{}
+	require.NoError(t, json.Unmarshal(data[0].GetPayload().([]byte), result))
+	return result, nil
+}
+
+func TestPullWorkflow(t *testing.T) {
+	t.Run("empty project", func(t *testing.T) {
+		dir := t.TempDir()
+		result, err := runPull(t, dir, false)
+		require.NoError(t, err)
+		assert.Equal(t, testOrgID, result.OrganizationID)
+		assert.Equal(t, testCustomRulesID, result.CustomRulesID)
+		assert.ElementsMatch(t, []string{
+			filepath.Join(dir, "rules/TEST_001/main.rego"),
+			filepath.Join(dir, "lib/utils.rego"),
+		}, result.Files)
+		assert.Empty(t, result.Removed)
+		assert.FileExists(t, filepath.Join(dir, "rules/TEST_001/main.rego"))
+
+		prj, err := project.FromDir(afero.NewOsFs(), dir)
+		require.NoError(t, err)
+		assert.Equal(t, "Test", prj.Manifest().Name)
+		assert.NotNil(t, prj.Manifest().PushForOrganization(testOrgID))
+	})
+
+	t.Run("existing rules without force", func(t *testing.T) {
+		dir := t.TempDir()
+		testutil.WriteFiles(t, dir, map[string]string{
+			"rules/OLD_001/main.rego": "package rules.OLD_001\n",
+		})
+		_, err := runPull(t, dir, false)
+		assert.ErrorContains(t, err, "already contains rules")
+		assert.NoFileExists(t, filepath.Join(dir, "rules/TEST_001/main.rego"))
+	})
+
+	t.Run("existing rules with force", func(t *testing.T) {
+		dir := t.TempDir()
+		testutil.WriteFiles(t, dir, map[string]string{
+			"manifest.json":            `{"name":"Local"}`,
+			"rules/OLD_001/main.rego":  "package rules.OLD_001\n",
+			"rules/TEST_001/main.rego": "package rules.TEST_001\n",
+			"rules/TEST_001/old.rego":  "package rules.TEST_001\n",
+			"lib/old.rego":             "package lib.old\n",
+		})
+		result, err := runPull(t, dir, true)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			filepath.Join(dir, "rules/OLD_001"),
+			filepath.Join(dir, "rules/TEST_001/old.rego"),
+			filepath.Join(dir, "lib/old.rego"),
+		}, result.Removed)
+		assert.NoDirExists(t, filepath.Join(dir, "rules/OLD_001"))
+		assert.NoFileExists(t, filepath.Join(dir, "rules/TEST_001/old.rego"))
+		assert.NoFileExists(t, filepath.Join(dir, "lib/old.rego"))
+		// The pulled rule is written as it is in the bundle.
+		contents, err := os.ReadFile(filepath.Join(dir, "rules/TEST_001/main.rego"))
+		require.NoError(t, err)
+		assert.Equal(t, testutil.Rule, string(contents))
+		assert.FileExists(t, filepath.Join(dir, "lib/utils.rego"))
+		assert.FileExists(t, filepath.Join(dir, "lib/relations.rego"))
+	})
+}
@@ -18,29 +18,84 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/khulnasoft-lab/go-application-framework/pkg/configuration"
 	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
-	"github.com/khulnasoft/policy-engine/pkg/bundle"
 	"github.com/spf13/afero"
 	"github.com/spf13/pflag"
 
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/bundle"
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
 	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/service"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
 )
 
 var ()
 
 const (
-	flagDelete = "delete"
+	flagDelete          = "delete"
+	flagTimeout         = "timeout"
+	flagDiff            = "diff"
+	flagPlan            = "plan"
+	flagForce           = "force"
+	flagOrgs            = "orgs"
+	flagAllManifestOrgs = "all-manifest-orgs"
 )
 
+// dataTypeResults is the type of the workflow data describing the outcome of a
+// push for each organization.
+const dataTypeResults = "results"
+
+const (
+	actionCreated = "created"
+	actionUpdated = "updated"
+	actionDeleted = "deleted"
+	// actionUnchanged is used when the upload was skipped because the
+	// bundle's content hash matches the one that was last pushed.
+	actionUnchanged = "unchanged"
+)
+
+// pushResult describes what happened to the rule bundle of an organization.
+// Changes is only set with --diff or --plan. With --plan, Action is the action
+// that would have been taken and DryRun is set. When pushing to the
+// organization failed, Error is set.
+type pushResult struct {
+	Action         string             `json:"action,omitempty"`
+	OrganizationID string             `json:"organization_id"`
+	CustomRulesID  string             `json:"custom_rules_id"`
+	ContentHash    string             `json:"content_hash,omitempty"`
+	LastPushedAt   *time.Time         `json:"last_pushed_at,omitempty"`
+	Changes        *bundle.Comparison `json:"changes,omitempty"`
+	DryRun         bool               `json:"dry_run,omitempty"`
+	Error          string             `json:"error,omitempty"`
+}
+
+// pushOptions are the options of a push that apply to every organization.
+type pushOptions struct {
+	del         bool
+	diff        bool
+	plan        bool
+	force       bool
+	timeout     time.Duration
+	contentHash string
+}
+
 func RegisterWorkflows(e workflow.Engine) error {
 	workflowID := workflow.NewWorkflowIdentifier("iac.rules.push")
 	flagset := pflag.NewFlagSet("vulnmap-cli-extension-iac-rules-push", pflag.ExitOnError)
 
 	flagset.Bool(flagDelete, false, "Delete upstream rule bundle")
+	flagset.Duration(flagTimeout, 2*time.Minute, "Maximum time to wait for the Vulnmap API")
+	flagset.Bool(flagDiff, false, "Show the changes to the deployed rule bundle before pushing")
+	flagset.Bool(flagPlan, false, "Show the changes to the deployed rule bundle without pushing")
+	flagset.Bool(flagForce, false, "Upload the rule bundle even if it is unchanged since the last push")
+	flagset.String(flagOrgs, "", "Comma-separated IDs of the organizations to push to, instead of the configured organization")
+	flagset.Bool(flagAllManifestOrgs, false, "Push to every organization in manifest.json")
+	utils.AddProjectDirFlag(flagset)
 
 	c := workflow.ConfigurationOptionsFromFlagset(flagset)
 
@@ -54,88 +109,257 @@ func pushWorkflow(
 	ictx workflow.InvocationContext,
 	_ []workflow.Data,
 ) ([]workflow.Data, error) {
-	ctx := context.Background()
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
 	logger := ictx.GetLogger()
 	config := ictx.GetConfiguration()
-	currentOrgID := config.GetString(configuration.ORGANIZATION)
-	del := ictx.GetConfiguration().GetBool(flagDelete)
+	timeout, err := utils.GetDuration(config, flagTimeout)
+	if err != nil {
+		return nil, err
+	}
 
-	prj, err := project.FromDir(afero.NewOsFs(), ".")
+	fsys := afero.NewOsFs()
+	root, err := project.Discover(fsys, config.GetString(utils.FlagProjectDir))
 	if err != nil {
 		return nil, err
 	}
-	bundled, err := bundle.BuildBundle(bundle.NewDirReader(prj.Path()))
+	prj, err := project.FromDir(fsys, root)
 	if err != nil {
 		return nil, err
 	}
-	if err := bundled.Validate(); err != nil {
+	manifest := prj.Manifest()
+	orgIDs, err := organizations(config, manifest)
+	if err != nil {
+		return nil, err
+	}
+	bundled, err := bundle.Build(prj)
+	if err != nil {
 		return nil, err
 	}
 	logger.Println("validated bundle")
-
-	targz := &bytes.Buffer{}
-	if err := bundle.NewTarGzWriter(targz).Write(bundled); err != nil {
+	contentHash, err := bundled.ContentHash()
+	if err != nil {
 		return nil, err
 	}
+	options := pushOptions{
+		del:         config.GetBool(flagDelete),
+		diff:        config.GetBool(flagDiff),
+		plan:        config.GetBool(flagPlan),
+		force:       config.GetBool(flagForce),
+		timeout:     timeout,
+		contentHash: contentHash,
+	}
 
 	client := service.NewClient(
 		ictx.GetNetworkAccess().GetHttpClient(),
 		config.GetString(configuration.API_URL),
 	)
-	manifest := prj.Manifest()
-	push := getManifestPushByOrganization(manifest, currentOrgID)
-	if push == nil && del {
-		return nil, fmt.Errorf("no rule bundle to delete")
-	} else if push == nil {
+	results := []pushResult{}
+	failed := 0
+	for _, orgID := range orgIDs {
+		if len(orgIDs) > 1 {
+			fmt.Fprintf(os.Stderr, "Organization %s:\n", orgID)
+		}
+		result, err := pushToOrganization(ctx, logger, client, &manifest, bundled, options, orgID)
+		if err == nil && !options.plan && result.Action != actionUnchanged {
+			// The manifest is written after each push, so that bundles that
+			// were created aren't lost when pushing to a later organization
+			// fails.
+			prj.UpdateManifest(manifest)
+			err = prj.WriteChanges()
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			result.Error = err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	data, err := utils.NewJSONData(ictx.GetWorkflowIdentifier(), dataTypeResults, results)
+	if err != nil {
+		return nil, err
+	}
+	if failed > 0 {
+		return []workflow.Data{data}, fmt.Errorf("failed to push to %d of %d organizations", failed, len(orgIDs))
+	}
+	return []workflow.Data{data}, nil
+}
+
+// organizations returns the IDs of the organizations to push to: the ones
+// given with --orgs, all organizations in the manifest with
+// --all-manifest-orgs, or else the configured organization.
+func organizations(config configuration.Configuration, manifest project.Manifest) ([]string, error) {
+	orgs := config.GetString(flagOrgs)
+	all := config.GetBool(flagAllManifestOrgs)
+	var orgIDs []string
+	switch {
+	case orgs != "" && all:
+		return nil, fmt.Errorf("--%s and --%s can't be used together", flagOrgs, flagAllManifestOrgs)
+	case orgs != "":
+		orgIDs = strings.Split(orgs, ",")
+	case all:
+		for _, p := range manifest.Push {
+			orgIDs = append(orgIDs, p.OrganizationID)
+		}
+		if len(orgIDs) < 1 {
+			return nil, fmt.Errorf("no organizations in manifest.json")
+		}
+	default:
+		orgIDs = []string{config.GetString(configuration.ORGANIZATION)}
+	}
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, orgID := range orgIDs {
+		orgID = strings.TrimSpace(orgID)
+		if orgID == "" || seen[orgID] {
+			continue
+		}
+		seen[orgID] = true
+		unique = append(unique, orgID)
+	}
+	if len(unique) < 1 {
+		return nil, fmt.Errorf("no organization configured")
+	}
+	return unique, nil
+}
+
+// pushToOrganization creates, updates or deletes the rule bundle of an
+// organization, and records the change in the manifest.
+func pushToOrganization(
+	ctx context.Context,
+	logger *log.Logger,
+	client *service.Client,
+	manifest *project.Manifest,
+	bundled *bundle.Bundle,
+	options pushOptions,
+	orgID string,
+) (pushResult, error) {
+	ctx, cancel := utils.WithTimeout(ctx, options.timeout)
+	defer cancel()
+	result := pushResult{OrganizationID: orgID}
+	push := manifest.PushForOrganization(orgID)
+	if push == nil && options.del {
+		return result, fmt.Errorf("no rule bundle to delete")
+	}
+	if push != nil {
+		result.LastPushedAt = push.PushedAt
+	}
+	unchanged := push != nil && !options.del && !options.force && push.ContentHash == options.contentHash
+	if !options.del {
+		result.ContentHash = options.contentHash
+	}
+	if options.plan || options.diff {
+		after := bundled
+		if options.del {
+			after = nil
+		}
+		changes, err := diffDeployed(ctx, client, push, after)
+		if err != nil {
+			return result, err
+		}
+		result.Changes = changes
+		result.Changes.Print(os.Stderr)
+	}
+	if options.plan {
+		result.DryRun = true
+		switch {
+		case push == nil:
+			result.Action = actionCreated
+		case options.del:
+			result.Action = actionDeleted
+			result.CustomRulesID = push.CustomRulesID
+		case unchanged:
+			result.Action = actionUnchanged
+			result.CustomRulesID = push.CustomRulesID
+		default:
+			result.Action = actionUpdated
+			result.CustomRulesID = push.CustomRulesID
+		}
+		fmt.Fprintf(os.Stderr, "Plan only, the rule bundle would have been %s.\n", result.Action)
+		return result, nil
+	}
+	if unchanged {
+		result.Action = actionUnchanged
+		result.CustomRulesID = push.CustomRulesID
+		fmt.Fprintf(os.Stderr, "Rule bundle is unchanged since it was last pushed%s (content hash %s), skipping upload.\n", formatPushedAt(push.PushedAt), options.contentHash)
+		return result, nil
+	}
+
+	pushedAt := time.Now().UTC().Truncate(time.Second)
+	if push == nil {
 		logger.Println("uploading new custom rules bundle")
-		customRulesID, err := client.CreateCustomRules(ctx, currentOrgID, targz.Bytes())
+		customRulesID, err := client.CreateCustomRules(ctx, orgID, bundled.TarGz())
 		if err != nil {
-			return nil, err
+			return result, err
 		}
+		result.Action = actionCreated
+		result.CustomRulesID = customRulesID
 
-		manifest.Push = append(manifest.Push, project.ManifestPush{
+		manifest.SetPush(project.ManifestPush{
 			CustomRulesID:  customRulesID,
-			OrganizationID: currentOrgID,
+			OrganizationID: orgID,
+			ContentHash:    options.contentHash,
+			PushedAt:       &pushedAt,
 		})
-		prj.UpdateManifest(manifest)
-		if err := prj.WriteChanges(); err != nil {
-			return nil, err
-		}
-	} else if del {
+		fmt.Fprintln(os.Stderr, "Successfully uploaded custom rule bundle.")
+	} else if options.del {
 		logger.Println("deleting custom rules bundle", push.CustomRulesID)
 		err := client.DeleteCustomRules(ctx, push.OrganizationID, push.CustomRulesID)
 		if err != nil {
-			return nil, err
+			return result, err
 		}
+		result.Action = actionDeleted
+		result.CustomRulesID = push.CustomRulesID
 
-		filtered := []project.ManifestPush{}
-		for _, p := range manifest.Push {
-			if p.OrganizationID != currentOrgID {
-				filtered = append(filtered, p)
-			}
-		}
-		manifest.Push = filtered
-		prj.UpdateManifest(manifest)
-		if err := prj.WriteChanges(); err != nil {
-			return nil, err
-		}
+		manifest.RemovePush(orgID)
+		fmt.Fprintln(os.Stderr, "Successfully deleted custom rule bundle.")
 	} else {
 		logger.Println("updating existing custom rules bundle", push.CustomRulesID)
-		err := client.UpdateCustomRules(ctx, push.OrganizationID, push.CustomRulesID, targz.Bytes())
+		err := client.UpdateCustomRules(ctx, push.OrganizationID, push.CustomRulesID, bundled.TarGz())
 		if err != nil {
-			return nil, err
+			return result, err
 		}
-	}
+		result.Action = actionUpdated
+		result.CustomRulesID = push.CustomRulesID
 
-	fmt.Fprintln(os.Stderr, "Successfully uploaded custom rule bundle.")
-	return []workflow.Data{}, nil
+		push.ContentHash = options.contentHash
+		push.PushedAt = &pushedAt
+		manifest.SetPush(*push)
+		fmt.Fprintln(os.Stderr, "Successfully uploaded custom rule bundle.")
+	}
+	return result, nil
 }
 
-func getManifestPushByOrganization(manifest project.Manifest, organizationID string) *project.ManifestPush {
-	for _, push := range manifest.Push {
-		if push.OrganizationID == organizationID {
-			return &push
+// diffDeployed compares the bundle that is deployed for the given push entry
+// with the bundle that will replace it. A nil push entry or bundle means that
+// there is no bundle.
+func diffDeployed(
+	ctx context.Context,
+	client *service.Client,
+	push *project.ManifestPush,
+	after *bundle.Bundle,
+) (*bundle.Comparison, error) {
+	var before *bundle.Bundle
+	if push != nil {
+		targz, err := client.GetCustomRules(ctx, push.OrganizationID, push.CustomRulesID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download deployed rule bundle: %w", err)
+		}
+		before, err = bundle.Read(push.CustomRulesID, bytes.NewReader(targz))
+		if err != nil {
+			return nil, err
 		}
 	}
-	return nil
+	return bundle.Compare(ctx, before, after)
+}
+
+func formatPushedAt(pushedAt *time.Time) string {
+	if pushedAt == nil {
+		return ""
+	}
+	return " at " + pushedAt.Format(time.RFC3339)
 }
@@ -0,0 +1,372 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/khulnasoft-lab/go-application-framework/pkg/configuration"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/bundle"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/testutil"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
+)
+
+func TestOrganizations(t *testing.T) {
+	manifest := project.Manifest{
+		Push: []project.ManifestPush{
+			{OrganizationID: "org1", CustomRulesID: "a"},
+			{OrganizationID: "org2", CustomRulesID: "b"},
+		},
+	}
+	testCases := []struct {
+		name     string
+		config   map[string]interface{}
+		manifest project.Manifest
+		expected []string
+		err      string
+	}{
+		{
+			name:     "configured organization",
+			config:   map[string]interface{}{configuration.ORGANIZATION: "org3"},
+			manifest: manifest,
+			expected: []string{"org3"},
+		},
+		{
+			name: "orgs flag",
+			config: map[string]interface{}{
+				configuration.ORGANIZATION: "org3",
+				flagOrgs:                   "org1, org4,org1,",
+			},
+			manifest: manifest,
+			expected: []string{"org1", "org4"},
+		},
+		{
+			name:     "all manifest orgs",
+			config:   map[string]interface{}{flagAllManifestOrgs: true},
+			manifest: manifest,
+			expected: []string{"org1", "org2"},
+		},
+		{
+			name:   "no manifest orgs",
+			config: map[string]interface{}{flagAllManifestOrgs: true},
+			err:    "no organizations in manifest.json",
+		},
+		{
+			name: "conflicting flags",
+			config: map[string]interface{}{
+				flagOrgs:            "org1",
+				flagAllManifestOrgs: true,
+			},
+			manifest: manifest,
+			err:      "--orgs and --all-manifest-orgs can't be used together",
+		},
+		{
+			name:     "no organization",
+			config:   map[string]interface{}{},
+			manifest: manifest,
+			err:      "no organization configured",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := configuration.NewInMemory()
+			for k, v := range tc.config {
+				config.Set(k, v)
+			}
+			orgIDs, err := organizations(config, tc.manifest)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, orgIDs)
+		})
+	}
+}
+
+// fakeAPI serves the rule bundle endpoints. Requests for organizations in
+// forbidden are rejected, and deployed is returned as the tarball of every
+// bundle.
+type fakeAPI struct {
+	forbidden map[string]bool
+	deployed  []byte
+	mu        sync.Mutex
+	requests  []string
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The path is /rest/orgs/<org>/cloud/rule_bundles[/<id>]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/rest/orgs/"), "/")
+	orgID := parts[0]
+	io.Copy(io.Discard, r.Body)
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Method+" "+orgID)
+	f.mu.Unlock()
+	if f.forbidden[orgID] {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"errors": [{"status": "403", "title": "Forbidden", "detail": "no access"}]}`)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Write(f.deployed)
+	case http.MethodPost:
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"data": {"id": "bundle-%s", "type": "rule_bundle"}}`, orgID)
+	case http.MethodPatch:
+		fmt.Fprintf(w, `{"data": {"id": "%s", "type": "rule_bundle"}}`, parts[len(parts)-1])
+	case http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestPushWorkflow(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, map[string]string{
+		"manifest.json":            `{"name":"Test","push":[{"organization_id":"org-a","custom_rules_id":"existing"}]}`,
+		"rules/TEST_001/main.rego": testutil.Rule,
+	})
+	api := &fakeAPI{forbidden: map[string]bool{"org-b": true}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	config := configuration.NewInMemory()
+	config.Set(configuration.API_URL, server.URL)
+	config.Set(utils.FlagProjectDir, dir)
+	config.Set(flagOrgs, "org-a,org-b,org-c")
+	ictx := testutil.InvocationContext("iac.rules.push", config)
+	data, err := pushWorkflow(ictx, nil)
+	assert.EqualError(t, err, "failed to push to 1 of 3 organizations")
+
+	// Every organization is attempted, even after one of them failed.
+	assert.Equal(t, []string{"PATCH org-a", "POST org-b", "POST org-c"}, api.requests)
+
+	require.Len(t, data, 1)
+	var results []pushResult
+	require.NoError(t, json.Unmarshal(data[0].GetPayload().([]byte), &results))
+	require.Len(t, results, 3)
+	assert.Equal(t, "org-a", results[0].OrganizationID)
+	assert.Equal(t, actionUpdated, results[0].Action)
+	assert.Equal(t, "existing", results[0].CustomRulesID)
+	assert.Empty(t, results[0].Error)
+	assert.Equal(t, "org-b", results[1].OrganizationID)
+	assert.Empty(t, results[1].Action)
+	assert.Equal(t, "403 Forbidden: no access", results[1].Error)
+	assert.Equal(t, "org-c", results[2].OrganizationID)
+	assert.Equal(t, actionCreated, results[2].Action)
+	assert.Equal(t, "bundle-org-c", results[2].CustomRulesID)
+
+	// The manifest records the successful pushes only.
+	prj, err := project.FromDir(afero.NewOsFs(), dir)
+	require.NoError(t, err)
+	manifest := prj.Manifest()
+	require.NotNil(t, manifest.PushForOrganization("org-a"))
+	assert.Equal(t, results[0].ContentHash, manifest.PushForOrganization("org-a").ContentHash)
+	assert.Nil(t, manifest.PushForOrganization("org-b"))
+	require.NotNil(t, manifest.PushForOrganization("org-c"))
+	assert.Equal(t, "bundle-org-c", manifest.PushForOrganization("org-c").CustomRulesID)
+}
+
+func TestPushWorkflowWritesManifestAfterEachOrganization(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, map[string]string{
+		"manifest.json":            `{"name":"Test"}`,
+		"rules/TEST_001/main.rego": testutil.Rule,
+	})
+	api := &fakeAPI{forbidden: map[string]bool{"org-b": true}}
+	var manifests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Record the manifest as it is on disk when each request is made.
+		contents, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+		require.NoError(t, err)
+		manifests = append(manifests, string(contents))
+		api.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	config := configuration.NewInMemory()
+	config.Set(configuration.API_URL, server.URL)
+	config.Set(utils.FlagProjectDir, dir)
+	config.Set(flagOrgs, "org-a,org-b")
+	ictx := testutil.InvocationContext("iac.rules.push", config)
+	_, err := pushWorkflow(ictx, nil)
+	assert.Error(t, err)
+	require.Len(t, manifests, 2)
+	assert.NotContains(t, manifests[0], "bundle-org-a")
+	assert.Contains(t, manifests[1], "bundle-org-a")
+}
+
+func TestPushWorkflowUnchanged(t *testing.T) {
+	testCases := []struct {
+		name     string
+		flag     string
+		requests []string
+		action   string
+	}{
+		{
+			name:   "skips the upload",
+			action: actionUnchanged,
+		},
+		{
+			name:     "force",
+			flag:     flagForce,
+			requests: []string{"PATCH org-a"},
+			action:   actionUpdated,
+		},
+		{
+			name:     "delete",
+			flag:     flagDelete,
+			requests: []string{"DELETE org-a"},
+			action:   actionDeleted,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			testutil.WriteFiles(t, dir, map[string]string{
+				"manifest.json":            `{"name":"Test"}`,
+				"rules/TEST_001/main.rego": testutil.Rule,
+			})
+			prj, err := project.FromDir(afero.NewOsFs(), dir)
+			require.NoError(t, err)
+			bundled, err := bundle.Build(prj)
+			require.NoError(t, err)
+			contentHash, err := bundled.ContentHash()
+			require.NoError(t, err)
+			testutil.WriteFiles(t, dir, map[string]string{
+				"manifest.json": fmt.Sprintf(`{"name":"Test","push":[{"organization_id":"org-a","custom_rules_id":"existing","content_hash":%q}]}`, contentHash),
+			})
+
+			api := &fakeAPI{}
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			config := configuration.NewInMemory()
+			config.Set(configuration.API_URL, server.URL)
+			config.Set(configuration.ORGANIZATION, "org-a")
+			config.Set(utils.FlagProjectDir, dir)
+			if tc.flag != "" {
+				config.Set(tc.flag, true)
+			}
+			ictx := testutil.InvocationContext("iac.rules.push", config)
+			data, err := pushWorkflow(ictx, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tc.requests, api.requests)
+
+			require.Len(t, data, 1)
+			var results []pushResult
+			require.NoError(t, json.Unmarshal(data[0].GetPayload().([]byte), &results))
+			require.Len(t, results, 1)
+			assert.Equal(t, tc.action, results[0].Action)
+			assert.Equal(t, "existing", results[0].CustomRulesID)
+		})
+	}
+}
+
+func TestPushWorkflowPlanAndDiff(t *testing.T) {
+	// The deployed bundle has the rule with a different severity.
+	deployedDir := t.TempDir()
+	testutil.WriteFiles(t, deployedDir, map[string]string{
+		"manifest.json":            `{"name":"Test"}`,
+		"rules/TEST_001/main.rego": testutil.Rule,
+	})
+	prj, err := project.FromDir(afero.NewOsFs(), deployedDir)
+	require.NoError(t, err)
+	deployed, err := bundle.Build(prj)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		flag     string
+		requests []string
+		dryRun   bool
+	}{
+		{
+			name:     "plan",
+			flag:     flagPlan,
+			requests: []string{"GET org-a"},
+			dryRun:   true,
+		},
+		{
+			name:     "diff",
+			flag:     flagDiff,
+			requests: []string{"GET org-a", "PATCH org-a"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			manifest := `{"name":"Test","push":[{"organization_id":"org-a","custom_rules_id":"existing"}]}`
+			testutil.WriteFiles(t, dir, map[string]string{
+				"manifest.json":            manifest,
+				"rules/TEST_001/main.rego": strings.Replace(testutil.Rule, `"high"`, `"low"`, 1),
+			})
+			api := &fakeAPI{deployed: deployed.TarGz()}
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			config := configuration.NewInMemory()
+			config.Set(configuration.API_URL, server.URL)
+			config.Set(configuration.ORGANIZATION, "org-a")
+			config.Set(utils.FlagProjectDir, dir)
+			config.Set(tc.flag, true)
+			ictx := testutil.InvocationContext("iac.rules.push", config)
+			data, err := pushWorkflow(ictx, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tc.requests, api.requests)
+
+			require.Len(t, data, 1)
+			var results []pushResult
+			require.NoError(t, json.Unmarshal(data[0].GetPayload().([]byte), &results))
+			require.Len(t, results, 1)
+			assert.Equal(t, actionUpdated, results[0].Action)
+			assert.Equal(t, tc.dryRun, results[0].DryRun)
+			changes := results[0].Changes
+			require.NotNil(t, changes)
+			assert.Empty(t, changes.Added)
+			assert.Empty(t, changes.Removed)
+			require.Len(t, changes.Modified, 1)
+			assert.Equal(t, "TEST-001", changes.Modified[0].ID)
+			assert.False(t, changes.Modified[0].CodeChanged)
+			assert.Equal(t, []bundle.MetadataChange{
+				{Field: "severity", Old: "high", New: "low"},
+			}, changes.Modified[0].Metadata)
+
+			contents, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+			require.NoError(t, err)
+			if tc.dryRun {
+				assert.Equal(t, manifest, string(contents))
+			} else {
+				assert.NotEqual(t, manifest, string(contents))
+			}
+		})
+	}
+}
@@ -15,7 +15,6 @@
 package repl
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 
@@ -33,12 +32,25 @@ const (
 	flagInput = "repl-input"
 )
 
+// dataTypeSession is the type of the workflow data describing the REPL
+// session.
+const dataTypeSession = "session"
+
+// replSession describes the project and input that a REPL session was
+// started with.
+type replSession struct {
+	ProjectDir string   `json:"project_dir"`
+	Input      string   `json:"input,omitempty"`
+	Init       []string `json:"init,omitempty"`
+}
+
 func RegisterWorkflows(e workflow.Engine) error {
 	workflowID := workflow.NewWorkflowIdentifier("iac.rules.repl")
 
 	flagset := pflag.NewFlagSet("vulnmap-cli-extension-iac-rules-repl", pflag.ExitOnError)
 	flagset.StringSlice(flagInit, []string{}, "Run commands on REPL initialization")
 	flagset.String(flagInput, "", "Input IaC file")
+	utils.AddProjectDirFlag(flagset)
 
 	c := workflow.ConfigurationOptionsFromFlagset(flagset)
 	if _, err := e.Register(workflowID, c, replWorkflow); err != nil {
@@ -51,12 +63,17 @@ func replWorkflow(
 	ictx workflow.InvocationContext,
 	_ []workflow.Data,
 ) ([]workflow.Data, error) {
-	ctx := context.Background()
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
 	init := ictx.GetConfiguration().GetStringSlice(flagInit)
 	inputPath := ictx.GetConfiguration().GetString(flagInput)
 
 	fs := afero.NewOsFs()
-	prj, err := project.FromDir(fs, ".")
+	root, err := project.Discover(fs, ictx.GetConfiguration().GetString(utils.FlagProjectDir))
+	if err != nil {
+		return nil, err
+	}
+	prj, err := project.FromDir(fs, root)
 	if err != nil {
 		return nil, err
 	}
@@ -85,5 +102,13 @@ func replWorkflow(
 		return nil, err
 	}
 
-	return []workflow.Data{}, nil
+	data, err := utils.NewJSONData(ictx.GetWorkflowIdentifier(), dataTypeSession, replSession{
+		ProjectDir: root,
+		Input:      inputPath,
+		Init:       init,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []workflow.Data{data}, nil
 }
@@ -36,6 +36,12 @@ type resourceObject struct {
 
 type attributes interface{}
 
+// links represents the pagination links of a document, as defined in
+// https://jsonapi.org/format/#fetching-pagination.
+type links struct {
+	Next string `json:"next,omitempty"`
+}
+
 // errorDocument represents a JSON API error document,
 type errorDocument struct {
 	JSONAPI jSONAPI       `json:"jsonapi"`
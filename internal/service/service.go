@@ -22,6 +22,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 )
 
 const version = "2023-05-22~experimental"
@@ -111,11 +112,122 @@ func (c *Client) DeleteCustomRules(
 	return parseResponse(rsp, http.StatusNoContent, nil)
 }
 
+// GetCustomRules downloads the tarball of a custom rules bundle.
+func (c *Client) GetCustomRules(
+	ctx context.Context,
+	orgID string,
+	customRulesID string,
+) ([]byte, error) {
+	url := fmt.Sprintf(
+		"%s/rest/orgs/%s/cloud/rule_bundles/%s?version=%s",
+		c.url,
+		orgID,
+		customRulesID,
+		version,
+	)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return readResponse(rsp, http.StatusOK)
+}
+
+// CustomRules describes a custom rules bundle stored in the Vulnmap API.
+type CustomRules struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+}
+
+// customRulesCollection is the response document when listing custom rules.
+type customRulesCollection struct {
+	JSONAPI jSONAPI `json:"jsonapi"`
+	Data    []struct {
+		ID         string `json:"id"`
+		Type       string `json:"type"`
+		Attributes struct {
+			Created time.Time `json:"created"`
+			Size    int64     `json:"size"`
+		} `json:"attributes"`
+	} `json:"data"`
+	Links links `json:"links"`
+}
+
+// ListCustomRules returns all custom rules bundles of an organization,
+// following pagination links until the last page.
+func (c *Client) ListCustomRules(ctx context.Context, orgID string) ([]CustomRules, error) {
+	url := fmt.Sprintf(
+		"%s/rest/orgs/%s/cloud/rule_bundles?version=%s",
+		c.url,
+		orgID,
+		version,
+	)
+	customRules := []CustomRules{}
+	visited := map[string]bool{}
+	for url != "" && !visited[url] {
+		visited[url] = true
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+		if err != nil {
+			return nil, err
+		}
+		rsp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var response customRulesCollection
+		if err := parseResponse(rsp, http.StatusOK, &response); err != nil {
+			return nil, err
+		}
+		for _, d := range response.Data {
+			customRules = append(customRules, CustomRules{
+				ID:      d.ID,
+				Created: d.Attributes.Created,
+				Size:    d.Attributes.Size,
+			})
+		}
+		url = c.resolveLink(response.Links.Next)
+	}
+	return customRules, nil
+}
+
+// resolveLink turns a pagination link into an absolute URL. The REST API
+// returns links relative to its /rest prefix.
+func (c *Client) resolveLink(link string) string {
+	switch {
+	case link == "":
+		return ""
+	case strings.HasPrefix(link, "http://"), strings.HasPrefix(link, "https://"):
+		return link
+	case strings.HasPrefix(link, "/rest/"):
+		return c.url + link
+	default:
+		return c.url + "/rest" + link
+	}
+}
+
 func parseResponse(rsp *http.Response, expectedStatusCode int, expectedDocument interface{}) error {
+	body, err := readResponse(rsp, expectedStatusCode)
+	if err != nil {
+		return err
+	}
+	if expectedDocument != nil {
+		return json.Unmarshal(body, expectedDocument)
+	}
+	return nil
+}
+
+// readResponse returns the body of the response, or an error if the response
+// doesn't have the expected status code.
+func readResponse(rsp *http.Response, expectedStatusCode int) ([]byte, error) {
 	body, err := io.ReadAll(rsp.Body)
 	defer rsp.Body.Close()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if rsp.StatusCode != expectedStatusCode {
@@ -125,14 +237,11 @@ func parseResponse(rsp *http.Response, expectedStatusCode int, expectedDocument
 			// surface to the user than the actual content of the error. Notably, this
 			// can occur when cerberus bounces the request, as it returns plain text
 			// bodies.
-			return fmt.Errorf("response %d: %s", rsp.StatusCode, string(body))
+			return nil, fmt.Errorf("response %d: %s", rsp.StatusCode, string(body))
 		}
-		return fmt.Errorf("%s", errorDocumentToString(errorDoc))
-	}
-	if expectedDocument != nil {
-		return json.Unmarshal(body, expectedDocument)
+		return nil, fmt.Errorf("%s", errorDocumentToString(errorDoc))
 	}
-	return nil
+	return body, nil
 }
 
 func errorDocumentToString(err errorDocument) string {
@@ -0,0 +1,98 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListCustomRules(t *testing.T) {
+	pages := map[string]string{
+		"": `{
+			"data": [{"id": "a", "type": "rule_bundle", "attributes": {"created": "2023-01-01T00:00:00Z", "size": 100}}],
+			"links": {"next": "/orgs/org/cloud/rule_bundles?version=v&starting_after=a"}
+		}`,
+		"a": `{
+			"data": [{"id": "b", "type": "rule_bundle", "attributes": {"created": "2023-01-02T00:00:00Z", "size": 200}}],
+			"links": {"next": "/rest/orgs/org/cloud/rule_bundles?version=v&starting_after=b"}
+		}`,
+		"b": `{"data": [], "links": {}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/orgs/org/cloud/rule_bundles", r.URL.Path)
+		page, ok := pages[r.URL.Query().Get("starting_after")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client(), server.URL)
+	customRules, err := client.ListCustomRules(context.Background(), "org")
+	require.NoError(t, err)
+	assert.Equal(t, []CustomRules{
+		{ID: "a", Created: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Size: 100},
+		{ID: "b", Created: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), Size: 200},
+	}, customRules)
+}
+
+func TestListCustomRulesError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"errors": [{"status": "403", "title": "Forbidden", "detail": "no access"}]}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client(), server.URL)
+	_, err := client.ListCustomRules(context.Background(), "org")
+	assert.EqualError(t, err, "403 Forbidden: no access")
+}
+
+func TestGetCustomRules(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/rest/orgs/org/cloud/rule_bundles/bundle", r.URL.Path)
+		assert.Equal(t, "application/octet-stream", r.Header.Get("Accept"))
+		fmt.Fprint(w, "targz")
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client(), server.URL)
+	targz, err := client.GetCustomRules(context.Background(), "org", "bundle")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("targz"), targz)
+}
+
+func TestGetCustomRulesError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errors": [{"status": "404", "title": "Not Found", "detail": "no such bundle"}]}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client(), server.URL)
+	_, err := client.GetCustomRules(context.Background(), "org", "bundle")
+	assert.EqualError(t, err, "404 Not Found: no such bundle")
+}
@@ -0,0 +1,258 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// assertions is the format of a spec's assertions file. Unlike an expected
+// output file, it only describes the parts of the output that matter to the
+// spec, so it doesn't break when unrelated details such as source locations
+// change. For example:
+//
+//	failing:
+//	  - aws_s3_bucket.public
+//	passing:
+//	  - aws_s3_bucket.private
+//	assertions:
+//	  - resource: aws_s3_bucket.public
+//	    path: $.resources[0].attributes[0].path[0]
+//	    equals: acl
+type assertions struct {
+	// Failing lists the IDs of resources that must have at least one failing
+	// result.
+	Failing []string `yaml:"failing"`
+	// Passing lists the IDs of resources that must only have passing
+	// results.
+	Passing []string `yaml:"passing"`
+	// Assertions are checked against individual values in the output.
+	Assertions []valueAssertion `yaml:"assertions"`
+}
+
+// valueAssertion checks a single value in the output. The path is a JSONPath
+// expression that is evaluated against the array of results or, when
+// resource is set, against the first result for that resource. Only the
+// root, child and index operators are supported, e.g.
+// $.resources[0]["id"].
+type valueAssertion struct {
+	Resource string      `yaml:"resource"`
+	Path     string      `yaml:"path"`
+	Equals   interface{} `yaml:"equals"`
+	// Exists checks whether the path exists instead of checking its value.
+	Exists *bool `yaml:"exists"`
+}
+
+// checkAssertionsFile reads the assertions file at the given path and checks
+// it against the actual output.
+func checkAssertionsFile(fsys afero.Fs, path string, actual []byte) ([]string, error) {
+	contents, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	a, err := parseAssertions(contents)
+	if err != nil {
+		return nil, fmt.Errorf("invalid assertions file %s: %w", path, err)
+	}
+	return a.check(actual)
+}
+
+func parseAssertions(contents []byte) (*assertions, error) {
+	a := &assertions{}
+	if err := yaml.Unmarshal(contents, a); err != nil {
+		return nil, err
+	}
+	for i, va := range a.Assertions {
+		if va.Path == "" {
+			return nil, fmt.Errorf("assertion %d: path is required", i)
+		}
+		if _, err := parseJSONPath(va.Path); err != nil {
+			return nil, fmt.Errorf("assertion %d: %w", i, err)
+		}
+	}
+	return a, nil
+}
+
+// check evaluates the assertions against the actual output and returns a
+// description of each failed assertion.
+func (a *assertions) check(actual []byte) ([]string, error) {
+	var results []interface{}
+	if err := json.Unmarshal(actual, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse actual output: %w", err)
+	}
+	byResource := map[string][]map[string]interface{}{}
+	for _, r := range results {
+		result, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if id, ok := result["resource_id"].(string); ok && id != "" {
+			byResource[id] = append(byResource[id], result)
+		}
+	}
+
+	var failures []string
+	for _, id := range a.Failing {
+		switch {
+		case len(byResource[id]) < 1:
+			failures = append(failures, fmt.Sprintf("%s: expected to fail, but has no results", id))
+		case !hasFailingResult(byResource[id]):
+			failures = append(failures, fmt.Sprintf("%s: expected to fail, but passed", id))
+		}
+	}
+	for _, id := range a.Passing {
+		switch {
+		case len(byResource[id]) < 1:
+			failures = append(failures, fmt.Sprintf("%s: expected to pass, but has no results", id))
+		case hasFailingResult(byResource[id]):
+			failures = append(failures, fmt.Sprintf("%s: expected to pass, but failed", id))
+		}
+	}
+	for _, va := range a.Assertions {
+		if f := va.check(results, byResource); f != "" {
+			failures = append(failures, f)
+		}
+	}
+	return failures, nil
+}
+
+func (va valueAssertion) check(results []interface{}, byResource map[string][]map[string]interface{}) string {
+	desc := va.Path
+	var root interface{} = results
+	if va.Resource != "" {
+		desc = fmt.Sprintf("%s %s", va.Resource, va.Path)
+		rs := byResource[va.Resource]
+		if len(rs) < 1 {
+			return fmt.Sprintf("%s: resource has no results", desc)
+		}
+		root = rs[0]
+	}
+	// The path has been validated in parseAssertions
+	path, _ := parseJSONPath(va.Path)
+	value, found := path.lookup(root)
+	if va.Exists != nil {
+		if found != *va.Exists {
+			return fmt.Sprintf("%s: expected exists to be %t", desc, *va.Exists)
+		}
+		return ""
+	}
+	if !found {
+		return fmt.Sprintf("%s: not found", desc)
+	}
+	expected, err := normalizeJSONValue(va.Equals)
+	if err != nil {
+		return fmt.Sprintf("%s: invalid expected value: %s", desc, err)
+	}
+	if !reflect.DeepEqual(expected, value) {
+		return fmt.Sprintf("%s: %s → %s", desc, formatJSONValue(expected), formatJSONValue(value))
+	}
+	return ""
+}
+
+func hasFailingResult(results []map[string]interface{}) bool {
+	for _, r := range results {
+		if passed, _ := r["passed"].(bool); !passed {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeJSONValue converts a value decoded from YAML to the types that
+// encoding/json produces, so that it can be compared to decoded output.
+func normalizeJSONValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// jsonPath is a parsed JSONPath expression. Each element is either a string
+// key or an int index.
+type jsonPath []interface{}
+
+func parseJSONPath(p string) (jsonPath, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(p), "$")
+	path := jsonPath{}
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid path %q: empty key", p)
+			}
+			path = append(path, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unterminated bracket", p)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if idx, err := strconv.Atoi(inner); err == nil {
+				path = append(path, idx)
+				continue
+			}
+			key, err := strconv.Unquote(strings.Replace(inner, "'", "\"", 2))
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: unsupported selector [%s]", p, inner)
+			}
+			path = append(path, key)
+		default:
+			return nil, fmt.Errorf("invalid path %q: unexpected %q", p, rest[0])
+		}
+	}
+	return path, nil
+}
+
+// lookup returns the value at this path and whether it exists.
+func (p jsonPath) lookup(v interface{}) (interface{}, bool) {
+	for _, elem := range p {
+		switch e := elem.(type) {
+		case string:
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if v, ok = m[e]; !ok {
+				return nil, false
+			}
+		case int:
+			a, ok := v.([]interface{})
+			if !ok || e < 0 || e >= len(a) {
+				return nil, false
+			}
+			v = a[e]
+		}
+	}
+	return v, true
+}
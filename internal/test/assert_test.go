@@ -0,0 +1,72 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssertions(t *testing.T) {
+	actual := []byte(`[
+  {"passed": false, "resource_id": "aws_s3_bucket.a", "severity": "high", "resources": [{"id": "aws_s3_bucket.a", "type": "aws_s3_bucket"}]},
+  {"passed": true, "resource_id": "aws_s3_bucket.b", "severity": "high"}
+]`)
+	t.Run("passing assertions", func(t *testing.T) {
+		a, err := parseAssertions([]byte(`
+failing: [aws_s3_bucket.a]
+passing: [aws_s3_bucket.b]
+assertions:
+  - resource: aws_s3_bucket.a
+    path: $.resources[0]['type']
+    equals: aws_s3_bucket
+  - path: $[1].passed
+    equals: true
+  - resource: aws_s3_bucket.b
+    path: $.message
+    exists: false
+`))
+		assert.NoError(t, err)
+		failures, err := a.check(actual)
+		assert.NoError(t, err)
+		assert.Empty(t, failures)
+	})
+	t.Run("failing assertions", func(t *testing.T) {
+		a, err := parseAssertions([]byte(`
+failing: [aws_s3_bucket.b]
+passing: [aws_s3_bucket.c]
+assertions:
+  - resource: aws_s3_bucket.a
+    path: $.severity
+    equals: low
+`))
+		assert.NoError(t, err)
+		failures, err := a.check(actual)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"aws_s3_bucket.b: expected to fail, but passed",
+			"aws_s3_bucket.c: expected to pass, but has no results",
+			`aws_s3_bucket.a $.severity: "low" → "high"`,
+		}, failures)
+	})
+	t.Run("invalid path", func(t *testing.T) {
+		_, err := parseAssertions([]byte(`
+assertions:
+  - path: $.resources[?(@.id)]
+`))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,191 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+)
+
+const (
+	compareModeText     = "text"
+	compareModeSemantic = "semantic"
+)
+
+func compareModes() []string {
+	return []string{
+		compareModeText,
+		compareModeSemantic,
+	}
+}
+
+func validateCompareMode(mode string) error {
+	for _, m := range compareModes() {
+		if m == mode {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported compare mode %q, expected one of %v", mode, compareModes())
+}
+
+// comparer compares the expected and actual output of a spec.
+type comparer struct {
+	mode string
+	// ignoreArrayOrder makes the semantic comparison treat arrays as
+	// unordered collections.
+	ignoreArrayOrder bool
+}
+
+// compare returns whether the expected and actual output match and, if they
+// don't, a human-readable diff.
+func (c comparer) compare(expectedPath, actualPath, expected, actual string) (bool, string) {
+	if c.mode == compareModeSemantic {
+		return c.compareSemantic(expected, actual)
+	}
+	if expected == actual {
+		return true, ""
+	}
+	edits := myers.ComputeEdits(span.URI(expectedPath), expected, actual)
+	return false, fmt.Sprint(gotextdiff.ToUnified(expectedPath, actualPath, expected, edits))
+}
+
+func (c comparer) compareSemantic(expected, actual string) (bool, string) {
+	var expectedValue, actualValue interface{}
+	// A missing or malformed expected file is treated as null so that it
+	// shows up in the diff.
+	_ = json.Unmarshal([]byte(expected), &expectedValue)
+	if err := json.Unmarshal([]byte(actual), &actualValue); err != nil {
+		return false, fmt.Sprintf("failed to parse actual output: %s\n", err)
+	}
+	diffs := jsonDiff("results", expectedValue, actualValue, c.ignoreArrayOrder)
+	if len(diffs) < 1 {
+		return true, ""
+	}
+	return false, strings.Join(diffs, "\n") + "\n"
+}
+
+// jsonDiff returns a path-based description of the differences between two
+// decoded JSON values. Object keys are always compared without regard to
+// order.
+func jsonDiff(path string, expected, actual interface{}, ignoreArrayOrder bool) []string {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := map[string]struct{}{}
+		for k := range e {
+			keys[k] = struct{}{}
+		}
+		for k := range a {
+			keys[k] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		var diffs []string
+		for _, k := range sorted {
+			p := fmt.Sprintf("%s.%s", path, k)
+			ev, inExpected := e[k]
+			av, inActual := a[k]
+			switch {
+			case !inExpected:
+				diffs = append(diffs, fmt.Sprintf("%s: added %s", p, formatJSONValue(av)))
+			case !inActual:
+				diffs = append(diffs, fmt.Sprintf("%s: removed %s", p, formatJSONValue(ev)))
+			default:
+				diffs = append(diffs, jsonDiff(p, ev, av, ignoreArrayOrder)...)
+			}
+		}
+		return diffs
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			break
+		}
+		if ignoreArrayOrder {
+			return unorderedArrayDiff(path, e, a)
+		}
+		var diffs []string
+		for i := 0; i < len(e) || i < len(a); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(e):
+				diffs = append(diffs, fmt.Sprintf("%s: added %s", p, formatJSONValue(a[i])))
+			case i >= len(a):
+				diffs = append(diffs, fmt.Sprintf("%s: removed %s", p, formatJSONValue(e[i])))
+			default:
+				diffs = append(diffs, jsonDiff(p, e[i], a[i], ignoreArrayOrder)...)
+			}
+		}
+		return diffs
+	}
+	if reflect.DeepEqual(expected, actual) {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("%s: %s → %s", path, formatJSONValue(expected), formatJSONValue(actual)),
+	}
+}
+
+// unorderedArrayDiff compares two arrays as multisets. Elements that only
+// occur in one of them are reported with their index in that array.
+func unorderedArrayDiff(path string, expected, actual []interface{}) []string {
+	matched := make([]bool, len(actual))
+	var diffs []string
+	for i, e := range expected {
+		found := false
+		for j, a := range actual {
+			if !matched[j] && len(jsonDiff("", e, a, true)) < 1 {
+				matched[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			diffs = append(diffs, fmt.Sprintf("%s[%d]: removed %s", path, i, formatJSONValue(e)))
+		}
+	}
+	for j, a := range actual {
+		if !matched[j] {
+			diffs = append(diffs, fmt.Sprintf("%s[%d]: added %s", path, j, formatJSONValue(a)))
+		}
+	}
+	return diffs
+}
+
+const maxFormattedValueLength = 80
+
+func formatJSONValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	r := []rune(string(b))
+	if len(r) > maxFormattedValueLength {
+		return string(r[:maxFormattedValueLength]) + "…"
+	}
+	return string(r)
+}
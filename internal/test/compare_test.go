@@ -0,0 +1,55 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareSemantic(t *testing.T) {
+	expected := `[
+  {"resource_id": "a", "passed": false},
+  {"resource_id": "b", "passed": true}
+]`
+	t.Run("ignores key order and formatting", func(t *testing.T) {
+		c := comparer{mode: compareModeSemantic}
+		ok, diff := c.compare("expected.json", "input.tf", expected,
+			`[{"passed": false, "resource_id": "a"}, {"passed": true, "resource_id": "b"}]`)
+		assert.True(t, ok)
+		assert.Empty(t, diff)
+	})
+	t.Run("reports changed values by path", func(t *testing.T) {
+		c := comparer{mode: compareModeSemantic}
+		ok, diff := c.compare("expected.json", "input.tf", expected,
+			`[{"resource_id": "a", "passed": false}, {"resource_id": "c", "passed": true, "ignored": false}]`)
+		assert.False(t, ok)
+		assert.Equal(t, "results[1].ignored: added false\nresults[1].resource_id: \"b\" → \"c\"\n", diff)
+	})
+	t.Run("array order", func(t *testing.T) {
+		reordered := `[{"resource_id": "b", "passed": true}, {"resource_id": "a", "passed": false}]`
+		ok, _ := comparer{mode: compareModeSemantic}.compare("expected.json", "input.tf", expected, reordered)
+		assert.False(t, ok)
+		ok, _ = comparer{mode: compareModeSemantic, ignoreArrayOrder: true}.compare("expected.json", "input.tf", expected, reordered)
+		assert.True(t, ok)
+	})
+	t.Run("text mode is exact", func(t *testing.T) {
+		c := comparer{mode: compareModeText}
+		ok, diff := c.compare("expected.json", "input.tf", expected, expected+"\n")
+		assert.False(t, ok)
+		assert.NotEmpty(t, diff)
+	})
+}
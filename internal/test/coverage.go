@@ -0,0 +1,81 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+
+	"github.com/khulnasoft/policy-engine/pkg/models"
+	"github.com/open-policy-agent/opa/cover"
+)
+
+// coverage collects line coverage of the project's rego code from both specs
+// and rego tests. Spec coverage is collected by replaying each spec with a
+// coverage tracer.
+type coverage struct {
+	cover    *cover.Cover
+	replayer *replayer
+}
+
+func newCoverage(r *replayer) *coverage {
+	return &coverage{
+		cover:    cover.New(),
+		replayer: r,
+	}
+}
+
+// tracer returns the coverage tracer, or nil when coverage is disabled.
+func (c *coverage) tracer() *cover.Cover {
+	if c == nil {
+		return nil
+	}
+	return c.cover
+}
+
+// traceSpec collects the coverage of evaluating the given rule against a spec
+// input.
+func (c *coverage) traceSpec(ctx context.Context, ruleID string, input *models.State) error {
+	_, err := c.replayer.replay(ctx, ruleID, input, c.cover)
+	return err
+}
+
+// report returns the coverage report for the project's modules. Files in rule
+// directories are only included if includeRuleDir returns true for them.
+func (c *coverage) report(includeRuleDir func(string) bool) cover.Report {
+	r := c.cover.Report(c.replayer.modules)
+	r.CoveredLines = 0
+	r.NotCoveredLines = 0
+	for file, fr := range r.Files {
+		if _, ok := c.replayer.modules[file]; !ok {
+			delete(r.Files, file)
+			continue
+		}
+		if dir, ok := ruleDirForFile(file); ok && !includeRuleDir(dir) {
+			delete(r.Files, file)
+			continue
+		}
+		r.CoveredLines += fr.CoveredLines
+		r.NotCoveredLines += fr.NotCoveredLines
+	}
+	r.Coverage = coveragePercentage(r.CoveredLines, r.NotCoveredLines)
+	return r
+}
+
+func coveragePercentage(covered, notCovered int) float64 {
+	if covered+notCovered == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(covered+notCovered)
+}
@@ -0,0 +1,311 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/open-policy-agent/opa/cover"
+	"github.com/spf13/afero"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
+)
+
+const (
+	coverageFormatLcov      = "lcov"
+	coverageFormatCobertura = "cobertura"
+)
+
+func coverageFormats() []string {
+	return []string{
+		coverageFormatLcov,
+		coverageFormatCobertura,
+	}
+}
+
+func validateCoverageFormat(format string) error {
+	for _, f := range coverageFormats() {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported coverage format %q, expected one of %v", format, coverageFormats())
+}
+
+// ruleCoverage is the coverage of all files in a rule directory.
+type ruleCoverage struct {
+	ruleDirName     string
+	coveredLines    int
+	notCoveredLines int
+}
+
+func (r ruleCoverage) percentage() float64 {
+	return coveragePercentage(r.coveredLines, r.notCoveredLines)
+}
+
+// coverageByRule groups the files in a coverage report by rule directory.
+// Files outside of the rules directory, e.g. in lib, are not included.
+func coverageByRule(r cover.Report) []ruleCoverage {
+	byDir := map[string]*ruleCoverage{}
+	for file, fr := range r.Files {
+		dir, ok := ruleDirForFile(file)
+		if !ok {
+			continue
+		}
+		rc, ok := byDir[dir]
+		if !ok {
+			rc = &ruleCoverage{ruleDirName: dir}
+			byDir[dir] = rc
+		}
+		rc.coveredLines += fr.CoveredLines
+		rc.notCoveredLines += fr.NotCoveredLines
+	}
+	rules := make([]ruleCoverage, 0, len(byDir))
+	for _, rc := range byDir {
+		rules = append(rules, *rc)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].ruleDirName < rules[j].ruleDirName
+	})
+	return rules
+}
+
+func ruleDirForFile(file string) (string, bool) {
+	parts := strings.Split(filepath.ToSlash(file), "/")
+	if len(parts) >= 3 && parts[0] == "rules" {
+		return parts[1], true
+	}
+	return "", false
+}
+
+func sortedCoverageFiles(r cover.Report) []string {
+	files := make([]string, 0, len(r.Files))
+	for f := range r.Files {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// writeCoverageSummary writes a human-readable summary of the coverage report,
+// including the lines that aren't covered by any spec or test.
+func writeCoverageSummary(w io.Writer, r cover.Report) {
+	fmt.Fprintln(w, "Coverage by file:")
+	for _, file := range sortedCoverageFiles(r) {
+		fr := r.Files[file]
+		fmt.Fprintf(w, "  %s: %.2f%%", file, coveragePercentage(fr.CoveredLines, fr.NotCoveredLines))
+		if len(fr.NotCovered) > 0 {
+			ranges := make([]string, len(fr.NotCovered))
+			for i, rg := range fr.NotCovered {
+				if rg.Start.Row == rg.End.Row {
+					ranges[i] = fmt.Sprint(rg.Start.Row)
+				} else {
+					ranges[i] = fmt.Sprintf("%d-%d", rg.Start.Row, rg.End.Row)
+				}
+			}
+			fmt.Fprintf(w, " (not covered: %s)", strings.Join(ranges, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "Coverage by rule:")
+	for _, rc := range coverageByRule(r) {
+		fmt.Fprintf(w, "  %s: %.2f%%\n", rc.ruleDirName, rc.percentage())
+	}
+	fmt.Fprintf(w, "Total coverage: %.2f%%\n", r.Coverage)
+}
+
+// rulesBelowThreshold returns the rules whose coverage is below the given
+// percentage.
+func rulesBelowThreshold(r cover.Report, threshold float64) []ruleCoverage {
+	var below []ruleCoverage
+	for _, rc := range coverageByRule(r) {
+		if rc.percentage() < threshold {
+			below = append(below, rc)
+		}
+	}
+	return below
+}
+
+func writeCoverageFile(r cover.Report, format string, path string) error {
+	buf := &bytes.Buffer{}
+	var err error
+	switch format {
+	case coverageFormatCobertura:
+		err = writeCobertura(buf, r)
+	default:
+		err = writeLcov(buf, r)
+	}
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(afero.NewOsFs(), path, buf.Bytes(), 0644)
+}
+
+// coverageLines returns every line in the file report with its hit count. The
+// coverage tracer doesn't count hits, so covered lines have a count of 1.
+func coverageLines(fr *cover.FileReport) []coverageLine {
+	var lines []coverageLine
+	for _, rg := range fr.Covered {
+		for row := rg.Start.Row; row <= rg.End.Row; row++ {
+			lines = append(lines, coverageLine{row: row, hits: 1})
+		}
+	}
+	for _, rg := range fr.NotCovered {
+		for row := rg.Start.Row; row <= rg.End.Row; row++ {
+			lines = append(lines, coverageLine{row: row})
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].row < lines[j].row
+	})
+	return lines
+}
+
+type coverageLine struct {
+	row  int
+	hits int
+}
+
+func writeLcov(w io.Writer, r cover.Report) error {
+	for _, file := range sortedCoverageFiles(r) {
+		fr := r.Files[file]
+		if _, err := fmt.Fprintf(w, "TN:\nSF:%s\n", file); err != nil {
+			return err
+		}
+		for _, l := range coverageLines(fr) {
+			if _, err := fmt.Fprintf(w, "DA:%d,%d\n", l.row, l.hits); err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(w, "LF:%d\nLH:%d\nend_of_record\n",
+			fr.CoveredLines+fr.NotCoveredLines, fr.CoveredLines)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type coberturaCoverage struct {
+	XMLName         xml.Name           `xml:"coverage"`
+	LineRate        string             `xml:"line-rate,attr"`
+	BranchRate      string             `xml:"branch-rate,attr"`
+	LinesCovered    int                `xml:"lines-covered,attr"`
+	LinesValid      int                `xml:"lines-valid,attr"`
+	BranchesCovered int                `xml:"branches-covered,attr"`
+	BranchesValid   int                `xml:"branches-valid,attr"`
+	Complexity      int                `xml:"complexity,attr"`
+	Version         string             `xml:"version,attr"`
+	Timestamp       int64              `xml:"timestamp,attr"`
+	Sources         []string           `xml:"sources>source"`
+	Packages        []coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Name       string           `xml:"name,attr"`
+	LineRate   string           `xml:"line-rate,attr"`
+	BranchRate string           `xml:"branch-rate,attr"`
+	Complexity int              `xml:"complexity,attr"`
+	Classes    []coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Name       string          `xml:"name,attr"`
+	Filename   string          `xml:"filename,attr"`
+	LineRate   string          `xml:"line-rate,attr"`
+	BranchRate string          `xml:"branch-rate,attr"`
+	Complexity int             `xml:"complexity,attr"`
+	Methods    struct{}        `xml:"methods"`
+	Lines      []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Number int `xml:"number,attr"`
+	Hits   int `xml:"hits,attr"`
+}
+
+func lineRate(covered, notCovered int) string {
+	return fmt.Sprintf("%.4f", coveragePercentage(covered, notCovered)/100)
+}
+
+// writeCobertura writes a Cobertura XML report. Each rule directory is
+// reported as a package and each file as a class. Files outside of the rules
+// directory are reported in a package named after their directory.
+func writeCobertura(w io.Writer, r cover.Report) error {
+	packages := map[string]*coberturaPackage{}
+	packageLines := map[string][2]int{}
+	for _, file := range sortedCoverageFiles(r) {
+		fr := r.Files[file]
+		name, ok := ruleDirForFile(file)
+		if !ok {
+			name = filepath.ToSlash(filepath.Dir(file))
+		}
+		pkg, ok := packages[name]
+		if !ok {
+			pkg = &coberturaPackage{Name: name, BranchRate: "0"}
+			packages[name] = pkg
+		}
+		class := coberturaClass{
+			Name:       filepath.Base(file),
+			Filename:   file,
+			LineRate:   lineRate(fr.CoveredLines, fr.NotCoveredLines),
+			BranchRate: "0",
+		}
+		for _, l := range coverageLines(fr) {
+			class.Lines = append(class.Lines, coberturaLine{Number: l.row, Hits: l.hits})
+		}
+		pkg.Classes = append(pkg.Classes, class)
+		counts := packageLines[name]
+		packageLines[name] = [2]int{counts[0] + fr.CoveredLines, counts[1] + fr.NotCoveredLines}
+	}
+
+	doc := coberturaCoverage{
+		LineRate:     lineRate(r.CoveredLines, r.NotCoveredLines),
+		BranchRate:   "0",
+		LinesCovered: r.CoveredLines,
+		LinesValid:   r.CoveredLines + r.NotCoveredLines,
+		Timestamp:    time.Now().Unix(),
+		Sources:      []string{"."},
+	}
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pkg := packages[name]
+		counts := packageLines[name]
+		pkg.LineRate = lineRate(counts[0], counts[1])
+		doc.Packages = append(doc.Packages, *pkg)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
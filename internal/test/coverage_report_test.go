@@ -0,0 +1,72 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/open-policy-agent/opa/cover"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCoverageReport() cover.Report {
+	return cover.Report{
+		Files: map[string]*cover.FileReport{
+			"rules/TEST_001/main.rego": {
+				Covered:         []cover.Range{{Start: cover.Position{Row: 3}, End: cover.Position{Row: 4}}},
+				NotCovered:      []cover.Range{{Start: cover.Position{Row: 6}, End: cover.Position{Row: 6}}},
+				CoveredLines:    2,
+				NotCoveredLines: 1,
+			},
+			"lib/utils.rego": {
+				Covered:      []cover.Range{{Start: cover.Position{Row: 2}, End: cover.Position{Row: 2}}},
+				CoveredLines: 1,
+			},
+		},
+		CoveredLines:    3,
+		NotCoveredLines: 1,
+		Coverage:        75,
+	}
+}
+
+func TestCoverageByRule(t *testing.T) {
+	rules := coverageByRule(testCoverageReport())
+	assert.Len(t, rules, 1)
+	assert.Equal(t, "TEST_001", rules[0].ruleDirName)
+	assert.InDelta(t, 66.67, rules[0].percentage(), 0.01)
+	assert.Len(t, rulesBelowThreshold(testCoverageReport(), 70), 1)
+	assert.Empty(t, rulesBelowThreshold(testCoverageReport(), 50))
+}
+
+func TestWriteLcov(t *testing.T) {
+	buf := &bytes.Buffer{}
+	assert.NoError(t, writeLcov(buf, testCoverageReport()))
+	assert.Equal(t, `TN:
+SF:lib/utils.rego
+DA:2,1
+LF:1
+LH:1
+end_of_record
+TN:
+SF:rules/TEST_001/main.rego
+DA:3,1
+DA:4,1
+DA:6,0
+LF:3
+LH:2
+end_of_record
+`, buf.String())
+}
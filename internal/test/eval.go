@@ -0,0 +1,106 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/policy-engine/pkg/engine"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
+)
+
+// specEvaluation holds the outcome of running the engine on a single rule
+// spec.
+type specEvaluation struct {
+	fixture *project.RuleSpec
+	ruleID  string
+	// ruleInputType is the input_type declared by the rule.
+	ruleInputType string
+	inputs        *utils.Inputs
+	actual        []byte
+	duration      time.Duration
+	err           error
+}
+
+// evaluateSpecs runs the engine on each of the given specs, using one worker
+// per engine. The engine keeps per-evaluation state, so it can't be shared
+// between workers. Results are normalized and stored in place so that callers
+// can process them in a deterministic order afterwards.
+func evaluateSpecs(ctx context.Context, engines []*engine.Engine, evals []*specEvaluation, norm normalizer, timeout time.Duration) {
+	work := make(chan *specEvaluation)
+	wg := sync.WaitGroup{}
+	for _, eng := range engines {
+		wg.Add(1)
+		go func(eng *engine.Engine) {
+			defer wg.Done()
+			for e := range work {
+				e.evaluate(ctx, eng, norm, timeout)
+			}
+		}(eng)
+	}
+	for _, e := range evals {
+		work <- e
+	}
+	close(work)
+	wg.Wait()
+}
+
+// evaluate runs the engine on the spec. The timeout limits the evaluation of
+// the rule, but not the loading of the input. Specs that already have an
+// error, e.g. because there is no rule for them, are not evaluated.
+func (e *specEvaluation) evaluate(ctx context.Context, eng *engine.Engine, norm normalizer, timeout time.Duration) {
+	start := time.Now()
+	defer func() {
+		e.duration = time.Since(start)
+	}()
+	if e.err != nil {
+		return
+	}
+	if err := ctx.Err(); err != nil {
+		e.err = err
+		return
+	}
+	inputs, err := loadInput(e.fixture.Input.Path())
+	if err != nil {
+		e.err = fmt.Errorf("error loading input: %w", err)
+		return
+	}
+	e.inputs = inputs
+	for _, s := range inputs.States {
+		if err := checkInputType(e.ruleInputType, s.InputType); err != nil {
+			e.err = err
+			return
+		}
+	}
+	evalCtx, cancel := utils.WithTimeout(ctx, timeout)
+	defer cancel()
+	results, err := runEngine(evalCtx, eng, e.ruleID, inputs)
+	if errors.Is(evalCtx.Err(), context.DeadlineExceeded) {
+		e.err = fmt.Errorf("evaluation timed out after %s", timeout)
+		return
+	}
+	if err != nil {
+		e.err = fmt.Errorf("error running engine: %w", err)
+		return
+	}
+	e.actual, e.err = norm.normalize(results, filepath.Dir(e.fixture.Input.Path()))
+}
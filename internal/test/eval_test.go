@@ -0,0 +1,167 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/testutil"
+)
+
+func TestLoadInput(t *testing.T) {
+	testCases := []struct {
+		name           string
+		files          map[string]string
+		expectedStates []string
+	}{
+		{
+			name: "terraform module",
+			files: map[string]string{
+				"main.tf":          "variable \"name\" {}\nmodule \"sub\" {\n  source = \"./sub\"\n  name = var.name\n}\n",
+				"terraform.tfvars": "name = \"bucket\"\n",
+				"sub/main.tf":      "variable \"name\" {}\nresource \"aws_s3_bucket\" \"b\" {\n  bucket = var.name\n}\n",
+			},
+			expectedStates: []string{"tf_hcl"},
+		},
+		{
+			name: "terraform plan",
+			files: map[string]string{
+				"plan.json": `{
+  "terraform_version": "1.5.0",
+  "planned_values": {
+    "root_module": {
+      "resources": [
+        {
+          "address": "aws_s3_bucket.b",
+          "mode": "managed",
+          "type": "aws_s3_bucket",
+          "name": "b",
+          "values": {"bucket": "bucket"}
+        }
+      ]
+    }
+  },
+  "configuration": {
+    "root_module": {}
+  }
+}`,
+			},
+			expectedStates: []string{"tf_plan"},
+		},
+		{
+			name: "multiple configurations",
+			files: map[string]string{
+				"one/main.tf": "resource \"aws_s3_bucket\" \"a\" {}\n",
+				"two/main.tf": "resource \"aws_s3_bucket\" \"b\" {}\n",
+				"pod.yaml":    "apiVersion: v1\nkind: Pod\nmetadata:\n  name: pod\n",
+			},
+			expectedStates: []string{"k8s", "tf_hcl", "tf_hcl"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			testutil.WriteFiles(t, dir, tc.files)
+			inputs, err := loadInput(dir)
+			require.NoError(t, err)
+			var states []string
+			for _, s := range inputs.States {
+				states = append(states, s.InputType)
+			}
+			assert.ElementsMatch(t, tc.expectedStates, states)
+		})
+	}
+
+	t.Run("invalid terraform plan", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "plan.json")
+		contents := `{"terraform_version": "1.5.0", "planned_values": {"root_module": {}}}`
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+		_, err := loadInput(path)
+		assert.ErrorContains(t, err, "failed to load "+path)
+	})
+
+	t.Run("no inputs", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme"), 0644))
+		_, err := loadInput(dir)
+		assert.EqualError(t, err, "no inputs found in "+dir)
+	})
+}
+
+func TestEvaluateCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e := &specEvaluation{
+		fixture: &project.RuleSpec{
+			RuleDirName: "TEST_001",
+			Input:       project.ExistingFile("spec/rules/TEST_001/inputs/infra.tf"),
+		},
+		ruleID: "TEST-001",
+	}
+	e.evaluate(ctx, nil, normalizer{}, 0)
+	assert.ErrorIs(t, e.err, context.Canceled)
+}
+
+func TestRunTestsMissingRule(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"manifest.json":                           `{"name":"Test"}`,
+		"spec/rules/TEST_001/inputs/infra.tf":     "resource \"aws_s3_bucket\" \"b\" {}\n",
+		"spec/rules/TEST_001/expected/infra.json": "[]",
+	}
+	testutil.WriteFiles(t, dir, files)
+	filter, err := newTestFilter(nil, nil)
+	require.NoError(t, err)
+	options := testOptions{
+		fs:          afero.NewOsFs(),
+		root:        dir,
+		filter:      filter,
+		comparer:    comparer{mode: compareModeText},
+		parallelism: 1,
+		regoOutput:  io.Discard,
+	}
+
+	t.Run("not strict", func(t *testing.T) {
+		rpt, err := runTests(context.Background(), options)
+		require.NoError(t, err)
+		assert.Empty(t, rpt.Specs)
+		require.Len(t, rpt.Problems, 1)
+		assert.Equal(t, problemOrphanedSpecDir, rpt.Problems[0].Kind)
+		assert.True(t, rpt.Passed)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		options := options
+		options.strict = true
+		rpt, err := runTests(context.Background(), options)
+		require.NoError(t, err)
+		require.Len(t, rpt.Specs, 1)
+		assert.Equal(t, statusErrored, rpt.Specs[0].Status)
+		assert.Contains(t, rpt.Specs[0].Error, "no rule found")
+		require.Len(t, rpt.Problems, 1)
+		assert.Equal(t, problemOrphanedSpecDir, rpt.Problems[0].Kind)
+		assert.False(t, rpt.Passed)
+	})
+}
@@ -0,0 +1,225 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/khulnasoft/policy-engine/pkg/engine"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/tester"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+)
+
+// testFilter selects the rules and specs that are run by the test workflow.
+// The rules and specs fields contain glob patterns. An empty list selects
+// everything.
+type testFilter struct {
+	rules []string
+	specs []string
+	// ruleDirs further restricts the selected rules to the given rule or
+	// spec directory names. It is ignored when nil.
+	ruleDirs map[string]bool
+	// ruleIDs contains the IDs of the rules in ruleDirs. It is set by
+	// resolveRuleDirs.
+	ruleIDs map[string]bool
+}
+
+// withRuleDirs returns a copy of this filter that only selects rules in the
+// given rule directories.
+func (f *testFilter) withRuleDirs(ruleDirs map[string]bool) *testFilter {
+	return &testFilter{
+		rules:    f.rules,
+		specs:    f.specs,
+		ruleDirs: ruleDirs,
+	}
+}
+
+func newTestFilter(rules []string, specs []string) (*testFilter, error) {
+	for _, p := range append(append([]string{}, rules...), specs...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	return &testFilter{
+		rules: rules,
+		specs: specs,
+	}, nil
+}
+
+// matchesRule returns whether a rule is selected, based on its ID or any of its
+// alternate names, e.g. its directory name.
+func (f *testFilter) matchesRule(ruleID string, alternates ...string) bool {
+	if !globsMatch(f.rules, append([]string{ruleID}, alternates...)...) {
+		return false
+	}
+	if f.ruleDirs == nil {
+		return true
+	}
+	if f.ruleIDs[ruleID] {
+		return true
+	}
+	for _, a := range alternates {
+		if f.ruleDirs[a] {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveRuleDirs returns a copy of this filter that also selects the rules
+// whose rule or spec directory is in ruleDirs. Rule directories are named
+// after the rule's package and spec directories after its ID, and these
+// differ for IDs that contain dots, so both are looked up from the rule
+// metadata.
+func (f *testFilter) resolveRuleDirs(ctx context.Context, eng *engine.Engine) (*testFilter, error) {
+	if f.ruleDirs == nil {
+		return f, nil
+	}
+	metadata, err := eng.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ruleIDs := map[string]bool{}
+	for _, mdr := range metadata {
+		ruleID := mdr.Metadata.ID
+		if ruleID == "" {
+			continue
+		}
+		ruleDirName, err := project.RuleIDToSafeFileName(ruleID)
+		if err != nil {
+			return nil, err
+		}
+		packageName, err := project.SafePackageName(ruleID)
+		if err != nil {
+			return nil, err
+		}
+		if f.ruleDirs[ruleDirName] || f.ruleDirs[packageName] {
+			ruleIDs[ruleID] = true
+		}
+	}
+	return &testFilter{
+		rules:    f.rules,
+		specs:    f.specs,
+		ruleDirs: f.ruleDirs,
+		ruleIDs:  ruleIDs,
+	}, nil
+}
+
+func (f *testFilter) selectsAllRules() bool {
+	return len(f.rules) < 1 && f.ruleDirs == nil
+}
+
+// matchesSpec returns whether the given spec is selected. Patterns are
+// matched against the spec name with and without its extension.
+func (f *testFilter) matchesSpec(spec *project.RuleSpec) bool {
+	name := spec.Name()
+	return globsMatch(f.specs, name, strings.TrimSuffix(name, filepath.Ext(name)))
+}
+
+// rulePackages returns the packages of the rules that are selected by this
+// filter, or nil when all rules are selected.
+func (f *testFilter) rulePackages(ctx context.Context, eng *engine.Engine) ([]string, error) {
+	if f.selectsAllRules() {
+		return nil, nil
+	}
+	metadata, err := eng.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	packages := []string{}
+	for _, mdr := range metadata {
+		ruleID := mdr.Metadata.ID
+		if ruleID == "" {
+			continue
+		}
+		ruleDirName, err := project.RuleIDToSafeFileName(ruleID)
+		if err != nil {
+			return nil, err
+		}
+		packageName, err := project.SafePackageName(ruleID)
+		if err != nil {
+			return nil, err
+		}
+		if f.matchesRule(ruleID, ruleDirName, packageName) {
+			packages = append(packages, mdr.Package)
+		}
+	}
+	if len(packages) < 1 && f.ruleDirs == nil {
+		return nil, fmt.Errorf("no rules match %s", strings.Join(f.rules, ", "))
+	}
+	return packages, nil
+}
+
+func globsMatch(patterns []string, names ...string) bool {
+	if len(patterns) < 1 {
+		return true
+	}
+	for _, p := range patterns {
+		for _, n := range names {
+			// Patterns have been validated in newTestFilter
+			if matched, _ := path.Match(p, n); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// removeTests removes test rules from all modules that are not in one of the
+// given packages or in a sub-package or "_test" package of them.
+func removeTests(modules map[string]*ast.Module, packages []string) {
+	for _, m := range modules {
+		if inPackages(m.Package.Path.String(), packages) {
+			continue
+		}
+		rules := m.Rules[:0]
+		for _, r := range m.Rules {
+			if !isTestRule(r) {
+				rules = append(rules, r)
+			}
+		}
+		m.Rules = rules
+	}
+}
+
+func inPackages(pkg string, packages []string) bool {
+	for _, p := range packages {
+		if pkg == p || strings.HasPrefix(pkg, p+".") || strings.HasPrefix(pkg, p+"_test") {
+			return true
+		}
+	}
+	return false
+}
+
+func isTestRule(r *ast.Rule) bool {
+	ref := r.Head.Ref()
+	if len(ref) < 1 {
+		return false
+	}
+	var name string
+	switch last := ref[len(ref)-1].Value.(type) {
+	case ast.Var:
+		name = string(last)
+	case ast.String:
+		name = string(last)
+	}
+	return strings.HasPrefix(name, tester.TestPrefix) || strings.HasPrefix(name, tester.SkipTestPrefix)
+}
@@ -0,0 +1,97 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+)
+
+func TestTestFilter(t *testing.T) {
+	t.Run("empty filter matches everything", func(t *testing.T) {
+		f, err := newTestFilter(nil, nil)
+		assert.NoError(t, err)
+		assert.True(t, f.matchesRule("TEST-001"))
+	})
+	t.Run("matches rule ID or alternate names", func(t *testing.T) {
+		f, err := newTestFilter([]string{"TEST-00*", "OTHER_001"}, nil)
+		assert.NoError(t, err)
+		assert.True(t, f.matchesRule("TEST-001"))
+		assert.True(t, f.matchesRule("OTHER-001", "OTHER_001"))
+		assert.False(t, f.matchesRule("OTHER-001", "OTHER-001"))
+	})
+	t.Run("rejects invalid patterns", func(t *testing.T) {
+		_, err := newTestFilter(nil, []string{"[invalid"})
+		assert.Error(t, err)
+	})
+}
+
+func TestResolveRuleDirs(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fsys, "project/manifest.json", []byte(`{"name":"Test"}`), 0644))
+	require.NoError(t, afero.WriteFile(fsys, "project/rules/TEST_001/main.rego", []byte(`package rules.TEST_001
+
+input_type := "tf"
+
+metadata := {"id": "TEST.001"}
+
+deny[info] {
+	info := {"resource": input}
+}
+`), 0644))
+	prj, err := project.FromDir(fsys, "project")
+	require.NoError(t, err)
+	ctx := context.Background()
+	eng, err := prj.Engine(ctx)
+	require.NoError(t, err)
+
+	for _, ruleDir := range []string{"TEST_001", "TEST.001"} {
+		t.Run(ruleDir, func(t *testing.T) {
+			f, err := newTestFilter(nil, nil)
+			require.NoError(t, err)
+			f, err = f.withRuleDirs(map[string]bool{ruleDir: true}).resolveRuleDirs(ctx, eng)
+			require.NoError(t, err)
+			assert.True(t, f.matchesRule("TEST.001", "TEST.001"))
+			assert.False(t, f.matchesRule("TEST-002", "TEST-002"))
+		})
+	}
+}
+
+func TestRemoveTests(t *testing.T) {
+	modules := map[string]*ast.Module{
+		"rules/TEST_001/main_test.rego": ast.MustParseModule(`package rules.TEST_001
+test_one { true }
+`),
+		"rules/TEST_0010/main_test.rego": ast.MustParseModule(`package rules.TEST_0010
+deny { true }
+test_two { true }
+`),
+		"rules/TEST_002/main_test.rego": ast.MustParseModule(`package rules.TEST_002_test
+test_three { true }
+`),
+	}
+	removeTests(modules, []string{"data.rules.TEST_001", "data.rules.TEST_002"})
+	assert.Len(t, modules["rules/TEST_001/main_test.rego"].Rules, 1)
+	assert.Len(t, modules["rules/TEST_0010/main_test.rego"].Rules, 1)
+	assert.Equal(t, "deny", modules["rules/TEST_0010/main_test.rego"].Rules[0].Head.Name.String())
+	assert.Len(t, modules["rules/TEST_002/main_test.rego"].Rules, 1)
+}
@@ -0,0 +1,40 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"fmt"
+
+	"github.com/khulnasoft/policy-engine/pkg/input"
+	"github.com/khulnasoft/policy-engine/pkg/policy"
+)
+
+// checkInputType returns an error when a spec input of the given type would
+// not be evaluated by a rule that declares ruleInputType. The engine skips
+// these rules silently, which would otherwise show up as empty results.
+func checkInputType(ruleInputType string, specInputType string) error {
+	if ruleInputType == "" {
+		return nil
+	}
+	t, err := policy.SupportedInputTypes.FromString(ruleInputType)
+	if err != nil {
+		// Unknown input types are matched by name, like the engine does.
+		t = &input.Type{Name: ruleInputType}
+	}
+	if !t.Matches(specInputType) {
+		return fmt.Errorf("spec input has type %q, which does not match the rule's input_type %q", specInputType, ruleInputType)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckInputType(t *testing.T) {
+	testCases := []struct {
+		name          string
+		ruleInputType string
+		specInputType string
+		expectedError string
+	}{
+		{name: "no rule input type", ruleInputType: "", specInputType: "cfn"},
+		{name: "exact match", ruleInputType: "cfn", specInputType: "cfn"},
+		{name: "alias", ruleInputType: "cloudformation", specInputType: "cfn"},
+		{name: "aggregate", ruleInputType: "tf", specInputType: "tf_hcl"},
+		{name: "custom type", ruleInputType: "custom", specInputType: "custom"},
+		{
+			name:          "mismatch",
+			ruleInputType: "tf",
+			specInputType: "cfn",
+			expectedError: `spec input has type "cfn", which does not match the rule's input_type "tf"`,
+		},
+		{
+			name:          "custom type mismatch",
+			ruleInputType: "custom",
+			specInputType: "k8s",
+			expectedError: `spec input has type "k8s", which does not match the rule's input_type "custom"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkInputType(tc.ruleInputType, tc.specInputType)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/khulnasoft/policy-engine/pkg/models"
+)
+
+// normalizer makes spec output reproducible. File paths in the output are
+// made relative to the directory that contains the spec input, so that they
+// don't depend on where the project is or where the spec was loaded from.
+type normalizer struct {
+	// ignoreFields are dot-separated paths of fields that are removed from
+	// each rule result.
+	ignoreFields []string
+}
+
+// normalize returns the normalized JSON output for the given rule results. The
+// results are modified in place.
+func (n normalizer) normalize(results []models.RuleResult, inputsDir string) ([]byte, error) {
+	for i := range results {
+		relativizeRuleResult(&results[i], inputsDir)
+	}
+	if len(n.ignoreFields) < 1 {
+		return json.MarshalIndent(results, "", "  ")
+	}
+	b, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return nil, err
+	}
+	for _, field := range n.ignoreFields {
+		removeField(generic, strings.Split(field, "."))
+	}
+	return json.MarshalIndent(generic, "", "  ")
+}
+
+// normalizeExpected normalizes an existing expected output file, so that
+// snapshots that were written before normalization was introduced, or with
+// different settings, still match. Only the path fields and the ignored fields
+// are changed, and any other fields are kept as they are. It also returns
+// whether any of those fields were changed. Contents that can't be parsed are
+// returned unchanged so that they show up in the diff.
+func (n normalizer) normalizeExpected(expected string, inputsDir string) (string, bool) {
+	if expected == "" {
+		return expected, false
+	}
+	var generic interface{}
+	if err := json.Unmarshal([]byte(expected), &generic); err != nil {
+		return expected, false
+	}
+	changed := false
+	for _, field := range pathFields {
+		updateField(generic, field, func(path string) string {
+			rel := relativePath(inputsDir, path)
+			if rel != path {
+				changed = true
+			}
+			return rel
+		})
+	}
+	for _, field := range n.ignoreFields {
+		if removeField(generic, strings.Split(field, ".")) {
+			changed = true
+		}
+	}
+	b, err := json.MarshalIndent(generic, "", "  ")
+	if err != nil {
+		return expected, false
+	}
+	return string(b), changed
+}
+
+// pathFields are the fields of a rule result that contain file paths. They are
+// the JSON equivalent of the fields changed by relativizeRuleResult.
+var pathFields = [][]string{
+	{"resource_namespace"},
+	{"resources", "namespace"},
+	{"resources", "location", "filepath"},
+	{"resources", "attributes", "location", "filepath"},
+	{"graphs", "source", "namespace"},
+	{"graphs", "target", "namespace"},
+}
+
+func relativizeRuleResult(r *models.RuleResult, base string) {
+	r.ResourceNamespace = relativePath(base, r.ResourceNamespace)
+	for _, res := range r.Resources {
+		if res == nil {
+			continue
+		}
+		res.Namespace = relativePath(base, res.Namespace)
+		for i := range res.Location {
+			res.Location[i].Filepath = relativePath(base, res.Location[i].Filepath)
+		}
+		for _, attr := range res.Attributes {
+			if attr.Location != nil {
+				attr.Location.Filepath = relativePath(base, attr.Location.Filepath)
+			}
+		}
+	}
+	for _, graph := range r.Graphs {
+		for _, edge := range graph {
+			for _, node := range []*models.Node{edge.Source, edge.Target} {
+				if node != nil {
+					node.Namespace = relativePath(base, node.Namespace)
+				}
+			}
+		}
+	}
+}
+
+// relativePath returns path relative to base when path is inside base.
+// Anything else, e.g. a namespace that isn't a path, is returned unchanged.
+func relativePath(base string, path string) string {
+	if path == "" {
+		return path
+	}
+	rel, err := filepath.Rel(base, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// removeField removes the fields at the given path and returns whether any
+// were found. Arrays along the path are traversed element by element.
+func removeField(v interface{}, path []string) bool {
+	removed := false
+	switch t := v.(type) {
+	case []interface{}:
+		for _, e := range t {
+			if removeField(e, path) {
+				removed = true
+			}
+		}
+	case map[string]interface{}:
+		if len(path) == 1 {
+			_, removed = t[path[0]]
+			delete(t, path[0])
+			return removed
+		}
+		if child, ok := t[path[0]]; ok {
+			removed = removeField(child, path[1:])
+		}
+	}
+	return removed
+}
+
+// updateField replaces the string values at the given path with the result
+// of f. Arrays along the path are traversed element by element.
+func updateField(v interface{}, path []string, f func(string) string) {
+	switch t := v.(type) {
+	case []interface{}:
+		for _, e := range t {
+			updateField(e, path, f)
+		}
+	case map[string]interface{}:
+		if len(path) == 1 {
+			if s, ok := t[path[0]].(string); ok {
+				t[path[0]] = f(s)
+			}
+			return
+		}
+		if child, ok := t[path[0]]; ok {
+			updateField(child, path[1:], f)
+		}
+	}
+}
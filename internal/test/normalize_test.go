@@ -0,0 +1,181 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft/policy-engine/pkg/models"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/testutil"
+)
+
+func testRuleResults() []models.RuleResult {
+	return []models.RuleResult{
+		{
+			ResourceId:        "aws_s3_bucket.a",
+			ResourceNamespace: "spec/rules/TEST_001/inputs/module",
+			Resources: []*models.RuleResultResource{
+				{
+					Id:        "aws_s3_bucket.a",
+					Namespace: "spec/rules/TEST_001/inputs/module",
+					Location: []models.SourceLocation{
+						{Filepath: "spec/rules/TEST_001/inputs/module/main.tf", Line: 1},
+					},
+					Attributes: []models.RuleResultResourceAttribute{
+						{
+							Path:     []interface{}{"bucket"},
+							Location: &models.SourceLocation{Filepath: "spec/rules/TEST_001/inputs/module/main.tf", Line: 2},
+						},
+					},
+				},
+				{
+					Id:        "cloud_resource",
+					Namespace: "us-east-1",
+				},
+			},
+		},
+	}
+}
+
+func TestNormalizer(t *testing.T) {
+	t.Run("relative paths", func(t *testing.T) {
+		results := testRuleResults()
+		_, err := normalizer{}.normalize(results, "spec/rules/TEST_001/inputs")
+		assert.NoError(t, err)
+		assert.Equal(t, "module", results[0].ResourceNamespace)
+		assert.Equal(t, "module", results[0].Resources[0].Namespace)
+		assert.Equal(t, "module/main.tf", results[0].Resources[0].Location[0].Filepath)
+		assert.Equal(t, "module/main.tf", results[0].Resources[0].Attributes[0].Location.Filepath)
+		assert.Equal(t, "us-east-1", results[0].Resources[1].Namespace)
+	})
+	t.Run("ignored fields", func(t *testing.T) {
+		n := normalizer{ignoreFields: []string{"resources.location", "resources.attributes.location"}}
+		b, err := n.normalize(testRuleResults(), "spec/rules/TEST_001/inputs")
+		assert.NoError(t, err)
+		assert.NotContains(t, string(b), "main.tf")
+		assert.Contains(t, string(b), `"bucket"`)
+	})
+	t.Run("expected output is normalized the same way", func(t *testing.T) {
+		old, err := normalizer{}.normalize(testRuleResults(), "elsewhere")
+		assert.NoError(t, err)
+		actual, err := normalizer{}.normalize(testRuleResults(), "spec/rules/TEST_001/inputs")
+		assert.NoError(t, err)
+		expected, changed := normalizer{}.normalizeExpected(string(old), "spec/rules/TEST_001/inputs")
+		assert.True(t, changed)
+		ok, diff := comparer{mode: compareModeSemantic}.compare("", "", expected, string(actual))
+		assert.True(t, ok, diff)
+		_, changed = normalizer{}.normalizeExpected(string(actual), "spec/rules/TEST_001/inputs")
+		assert.False(t, changed)
+		unparsed, changed := normalizer{}.normalizeExpected("not json", "spec/rules/TEST_001/inputs")
+		assert.Equal(t, "not json", unparsed)
+		assert.False(t, changed)
+	})
+	t.Run("expected output keeps unknown fields", func(t *testing.T) {
+		expected, _ := normalizer{}.normalizeExpected(
+			`[{"resource_namespace": "spec/rules/TEST_001/inputs/main.tf", "custom": {"a": 1}}]`,
+			"spec/rules/TEST_001/inputs",
+		)
+		ok, diff := comparer{mode: compareModeSemantic}.compare("", "", expected,
+			`[{"resource_namespace": "main.tf", "custom": {"a": 1}}]`)
+		assert.True(t, ok, diff)
+	})
+}
+
+// TestRunTestsPreNormalizationSnapshot checks that snapshots written before
+// spec output was normalized still pass the default text comparison.
+func TestRunTestsPreNormalizationSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "spec", "rules", "TEST_001", "inputs", "infra.tf")
+	expectedPath := filepath.Join(dir, "spec", "rules", "TEST_001", "expected", "infra.json")
+	files := map[string]string{
+		"manifest.json": `{"name":"Test"}`,
+		"rules/TEST_001/main.rego": `package rules.TEST_001
+
+input_type := "tf"
+
+resource_type := "aws_s3_bucket"
+
+metadata := {"id": "TEST_001"}
+
+deny {
+	input.acl == "public-read"
+}
+`,
+		"spec/rules/TEST_001/inputs/infra.tf": "resource \"aws_s3_bucket\" \"b\" {\n  acl = \"public-read\"\n}\n",
+	}
+	testutil.WriteFiles(t, dir, files)
+	filter, err := newTestFilter(nil, nil)
+	require.NoError(t, err)
+	options := testOptions{
+		fs:          afero.NewOsFs(),
+		root:        dir,
+		filter:      filter,
+		comparer:    comparer{mode: compareModeText},
+		parallelism: 1,
+		regoOutput:  io.Discard,
+	}
+	run := func(t *testing.T) *report {
+		rpt, err := runTests(context.Background(), options)
+		require.NoError(t, err)
+		require.Len(t, rpt.Specs, 1)
+		return rpt
+	}
+
+	update := options
+	update.updateExpected = true
+	_, err = runTests(context.Background(), update)
+	require.NoError(t, err)
+	normalized, err := os.ReadFile(expectedPath)
+	require.NoError(t, err)
+	require.Contains(t, string(normalized), `"infra.tf"`)
+
+	// Before normalization, snapshots contained the paths as they were
+	// passed to the loader, and were formatted differently.
+	var generic interface{}
+	require.NoError(t, json.Unmarshal(normalized, &generic))
+	compact, err := json.Marshal(generic)
+	require.NoError(t, err)
+	old := strings.ReplaceAll(string(compact), `"infra.tf"`, `"`+inputPath+`"`)
+
+	t.Run("pre-normalization snapshot", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(expectedPath, []byte(old), 0644))
+		rpt := run(t)
+		assert.Equal(t, statusPassed, rpt.Specs[0].Status, rpt.Specs[0].Diff)
+	})
+
+	t.Run("changed pre-normalization snapshot", func(t *testing.T) {
+		changed := strings.Replace(old, `"passed":false`, `"passed":true`, 1)
+		require.NotEqual(t, old, changed)
+		require.NoError(t, os.WriteFile(expectedPath, []byte(changed), 0644))
+		rpt := run(t)
+		assert.Equal(t, statusFailed, rpt.Specs[0].Status)
+	})
+
+	t.Run("reformatted normalized snapshot", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(expectedPath, compact, 0644))
+		rpt := run(t)
+		assert.Equal(t, statusFailed, rpt.Specs[0].Status)
+	})
+}
@@ -0,0 +1,126 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+)
+
+type specProblemKind string
+
+const (
+	problemOrphanedSpecDir  specProblemKind = "orphaned_spec_dir"
+	problemRuleWithoutSpecs specProblemKind = "rule_without_specs"
+	problemMissingExpected  specProblemKind = "missing_expected"
+)
+
+// specProblem describes a gap in the project's specs, such as a rule that
+// isn't tested by any spec.
+type specProblem struct {
+	Kind   specProblemKind `json:"kind"`
+	Path   string          `json:"path"`
+	RuleID string          `json:"rule_id,omitempty"`
+}
+
+func (p specProblem) message() string {
+	switch p.Kind {
+	case problemOrphanedSpecDir:
+		return "no rule found for spec directory"
+	case problemRuleWithoutSpecs:
+		return "rule " + p.RuleID + " has no specs"
+	case problemMissingExpected:
+		return "spec has no expected output or assertions"
+	default:
+		return string(p.Kind)
+	}
+}
+
+type specProblemsOptions struct {
+	root string
+	// specRuleDirs are the rule directory names in the spec directory.
+	specRuleDirs []string
+	fixtures     []*project.RuleSpec
+	// ruleDirNameToRuleID maps rule directory names to rule IDs for all
+	// rules in the project.
+	ruleDirNameToRuleID map[string]string
+	filter              *testFilter
+	// ignoreMissingExpected is set when missing expected files are about to
+	// be generated.
+	ignoreMissingExpected bool
+}
+
+// findSpecProblems returns spec directories without a rule, rules without
+// specs and specs without an expected output file, limited to the rules and
+// specs that are selected by the filter.
+func findSpecProblems(options specProblemsOptions) []specProblem {
+	filter := options.filter
+	specsDir := filepath.Join(options.root, "spec", "rules")
+	var problems []specProblem
+
+	specRuleDirs := append([]string{}, options.specRuleDirs...)
+	sort.Strings(specRuleDirs)
+	for _, dir := range specRuleDirs {
+		if _, ok := options.ruleDirNameToRuleID[dir]; ok || !filter.matchesRule("", dir) {
+			continue
+		}
+		problems = append(problems, specProblem{
+			Kind: problemOrphanedSpecDir,
+			Path: filepath.Join(specsDir, dir),
+		})
+	}
+
+	hasSpecs := map[string]bool{}
+	for _, f := range options.fixtures {
+		hasSpecs[f.RuleDirName] = true
+	}
+	ruleDirs := make([]string, 0, len(options.ruleDirNameToRuleID))
+	for dir := range options.ruleDirNameToRuleID {
+		ruleDirs = append(ruleDirs, dir)
+	}
+	sort.Strings(ruleDirs)
+	for _, dir := range ruleDirs {
+		ruleID := options.ruleDirNameToRuleID[dir]
+		if hasSpecs[dir] || !filter.matchesRule(ruleID, dir) {
+			continue
+		}
+		problems = append(problems, specProblem{
+			Kind:   problemRuleWithoutSpecs,
+			Path:   filepath.Join(specsDir, dir),
+			RuleID: ruleID,
+		})
+	}
+
+	if !options.ignoreMissingExpected {
+		for _, f := range options.fixtures {
+			ruleID, ok := options.ruleDirNameToRuleID[f.RuleDirName]
+			if !ok || f.Expected != nil || f.Assertions != nil {
+				continue
+			}
+			if !filter.matchesRule(ruleID, f.RuleDirName) || !filter.matchesSpec(f) {
+				continue
+			}
+			problems = append(problems, specProblem{
+				Kind:   problemMissingExpected,
+				Path:   f.Input.Path(),
+				RuleID: ruleID,
+			})
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,65 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+)
+
+func TestFindSpecProblems(t *testing.T) {
+	fixtures := []*project.RuleSpec{
+		{
+			RuleDirName: "TEST_001",
+			Input:       project.ExistingFile("spec/rules/TEST_001/inputs/infra.tf"),
+			Expected:    project.ExistingFile("spec/rules/TEST_001/expected/infra.json"),
+		},
+		{
+			RuleDirName: "TEST_001",
+			Input:       project.ExistingFile("spec/rules/TEST_001/inputs/no_expected.tf"),
+		},
+		{
+			RuleDirName: "ORPHAN",
+			Input:       project.ExistingFile("spec/rules/ORPHAN/inputs/infra.tf"),
+		},
+	}
+	options := specProblemsOptions{
+		root:         ".",
+		specRuleDirs: []string{"TEST_001", "ORPHAN"},
+		fixtures:     fixtures,
+		ruleDirNameToRuleID: map[string]string{
+			"TEST_001": "TEST-001",
+			"TEST_002": "TEST-002",
+		},
+	}
+
+	t.Run("all rules", func(t *testing.T) {
+		options.filter, _ = newTestFilter(nil, nil)
+		assert.Equal(t, []specProblem{
+			{Kind: problemOrphanedSpecDir, Path: "spec/rules/ORPHAN"},
+			{Kind: problemRuleWithoutSpecs, Path: "spec/rules/TEST_002", RuleID: "TEST-002"},
+			{Kind: problemMissingExpected, Path: "spec/rules/TEST_001/inputs/no_expected.tf", RuleID: "TEST-001"},
+		}, findSpecProblems(options))
+	})
+	t.Run("filtered rules", func(t *testing.T) {
+		options.filter, _ = newTestFilter([]string{"TEST-002"}, nil)
+		assert.Equal(t, []specProblem{
+			{Kind: problemRuleWithoutSpecs, Path: "spec/rules/TEST_002", RuleID: "TEST-002"},
+		}, findSpecProblems(options))
+	})
+}
@@ -0,0 +1,154 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/khulnasoft/policy-engine/pkg/engine"
+	"github.com/open-policy-agent/opa/profiler"
+)
+
+// profileHotSpots is the number of rego expressions that are reported for
+// each spec.
+const profileHotSpots = 10
+
+type profileOptions struct {
+	enabled    bool
+	iterations int
+}
+
+// specProfile contains the performance of evaluating a rule against a single
+// spec input. Allocations are averaged over all iterations.
+type specProfile struct {
+	RuleID     string           `json:"rule_id"`
+	Input      string           `json:"input"`
+	Iterations int              `json:"iterations"`
+	Min        time.Duration    `json:"min"`
+	P50        time.Duration    `json:"p50"`
+	P90        time.Duration    `json:"p90"`
+	P99        time.Duration    `json:"p99"`
+	Max        time.Duration    `json:"max"`
+	AllocBytes uint64           `json:"alloc_bytes"`
+	Allocs     uint64           `json:"allocs"`
+	HotSpots   []profileHotSpot `json:"hot_spots,omitempty"`
+}
+
+// profileHotSpot is a rego expression that took a large share of the
+// evaluation time.
+type profileHotSpot struct {
+	File     string        `json:"file"`
+	Row      int           `json:"row"`
+	Duration time.Duration `json:"duration"`
+	NumEval  int           `json:"num_eval"`
+}
+
+// profileSpec evaluates the rule against the spec input the given number of
+// times to measure its latency and allocations. The hot spots come from a
+// single replay of the evaluation with the OPA profiler attached, since the
+// engine doesn't accept tracers.
+func profileSpec(
+	ctx context.Context,
+	eng *engine.Engine,
+	rep *replayer,
+	eval *specEvaluation,
+	iterations int,
+) (*specProfile, error) {
+	if iterations < 1 {
+		iterations = 1
+	}
+	options := &engine.EvalOptions{
+		Inputs:  eval.inputs.States,
+		RuleIDs: []string{eval.ruleID},
+	}
+	durations := make([]time.Duration, iterations)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for i := range durations {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		eng.Eval(ctx, options)
+		durations[i] = time.Since(start)
+	}
+	runtime.ReadMemStats(&after)
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+
+	prof := profiler.New()
+	for i := range eval.inputs.States {
+		if _, err := rep.replay(ctx, eval.ruleID, &eval.inputs.States[i], prof); err != nil {
+			return nil, err
+		}
+	}
+	var hotSpots []profileHotSpot
+	for _, s := range prof.ReportTopNResults(profileHotSpots, []string{"total_time_ns"}) {
+		// Expressions without a file are part of the replayed queries
+		// themselves.
+		if s.Location == nil || s.Location.File == "" {
+			continue
+		}
+		hotSpots = append(hotSpots, profileHotSpot{
+			File:     s.Location.File,
+			Row:      s.Location.Row,
+			Duration: time.Duration(s.ExprTimeNs),
+			NumEval:  s.NumEval,
+		})
+	}
+
+	n := uint64(iterations)
+	return &specProfile{
+		RuleID:     eval.ruleID,
+		Input:      eval.fixture.Input.Path(),
+		Iterations: iterations,
+		Min:        durations[0],
+		P50:        percentile(durations, 50),
+		P90:        percentile(durations, 90),
+		P99:        percentile(durations, 99),
+		Max:        durations[len(durations)-1],
+		AllocBytes: (after.TotalAlloc - before.TotalAlloc) / n,
+		Allocs:     (after.Mallocs - before.Mallocs) / n,
+		HotSpots:   hotSpots,
+	}, nil
+}
+
+// percentile returns the nearest-rank percentile of the sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func writeProfile(w io.Writer, p *specProfile) {
+	fmt.Fprintf(w, "Profile for rule %s on %s (%d iterations):\n", p.RuleID, p.Input, p.Iterations)
+	fmt.Fprintf(w, "  latency: min %s, p50 %s, p90 %s, p99 %s, max %s\n", p.Min, p.P50, p.P90, p.P99, p.Max)
+	fmt.Fprintf(w, "  allocations per evaluation: %d bytes, %d allocs\n", p.AllocBytes, p.Allocs)
+	if len(p.HotSpots) > 0 {
+		fmt.Fprintln(w, "  hot spots:")
+		for _, h := range p.HotSpots {
+			fmt.Fprintf(w, "    %s:%d: %s (%d evals)\n", h.File, h.Row, h.Duration, h.NumEval)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/policy-engine/pkg/data"
+	"github.com/khulnasoft/policy-engine/pkg/engine"
+	"github.com/khulnasoft/policy-engine/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
+)
+
+func TestPercentile(t *testing.T) {
+	var durations []time.Duration
+	for i := 1; i <= 10; i++ {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, 5*time.Millisecond, percentile(durations, 50))
+	assert.Equal(t, 9*time.Millisecond, percentile(durations, 90))
+	assert.Equal(t, 10*time.Millisecond, percentile(durations, 99))
+	assert.Equal(t, time.Millisecond, percentile(durations[:1], 50))
+}
+
+// TestProfileSpec profiles the rules from TestReplayMatchesEngine, which checks
+// that the replayed evaluation matches the engine's.
+func TestProfileSpec(t *testing.T) {
+	ctx := context.Background()
+	providers := []data.Provider{data.FSProvider(replayFS, ".")}
+	eng := engine.NewEngine(ctx, &engine.EngineOptions{Providers: providers})
+	rep, err := newReplayer(ctx, eng, providers)
+	require.NoError(t, err)
+	eval := &specEvaluation{
+		fixture: &project.RuleSpec{
+			RuleDirName: "TEST_002",
+			Input:       project.ExistingFile("spec/rules/TEST_002/inputs/main.tf"),
+		},
+		ruleID: "TEST-002",
+		inputs: &utils.Inputs{States: []models.State{replayInput()}},
+	}
+	p, err := profileSpec(ctx, eng, rep, eval, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "TEST-002", p.RuleID)
+	assert.Equal(t, 3, p.Iterations)
+	assert.LessOrEqual(t, p.Min, p.Max)
+	files := map[string]bool{}
+	for _, h := range p.HotSpots {
+		files[h.File] = true
+	}
+	assert.True(t, files["rules/TEST_002/main.rego"], "hot spots: %v", p.HotSpots)
+}
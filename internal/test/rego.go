@@ -0,0 +1,126 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/khulnasoft/policy-engine/pkg/data"
+	"github.com/khulnasoft/policy-engine/pkg/engine"
+	"github.com/khulnasoft/policy-engine/pkg/policy"
+	"github.com/khulnasoft/policy-engine/pkg/snapshot_testing"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/cover"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/open-policy-agent/opa/tester"
+)
+
+type regoTestOptions struct {
+	Providers []data.Provider
+	Verbose   bool
+	Output    io.Writer
+	// Packages restricts the tests that are run to the given rule packages.
+	// All tests are run when this is nil.
+	Packages []string
+	// Coverage collects line coverage of the tests when set.
+	Coverage *cover.Cover
+}
+
+// runRegoTests runs the rego tests found in the given providers. This mirrors
+// Test from policy-engine's rego/test package, but it returns the individual
+// test results so that we can include them in reports.
+func runRegoTests(ctx context.Context, options regoTestOptions) ([]*tester.Result, error) {
+	providers := []data.Provider{
+		data.PureRegoBuiltinsProvider(),
+		data.PureRegoLibProvider(),
+	}
+	providers = append(providers, options.Providers...)
+	consumer := engine.NewPolicyConsumer()
+	for _, provider := range providers {
+		if err := provider(ctx, consumer); err != nil {
+			return nil, err
+		}
+	}
+	if options.Packages != nil {
+		removeTests(consumer.Modules, options.Packages)
+	}
+
+	store := inmem.New()
+	txn, err := store.NewTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Abort(ctx, txn)
+
+	capabilities := policy.Capabilities()
+	capabilities.Builtins = append(capabilities.Builtins, snapshot_testing.MatchBuiltin)
+
+	compiler := ast.NewCompiler().
+		WithCapabilities(capabilities).
+		WithEnablePrintStatements(true)
+
+	runner := tester.NewRunner().
+		AddCustomBuiltins([]*tester.Builtin{
+			{
+				Decl: snapshot_testing.MatchBuiltin,
+				Func: rego.FunctionDyn(
+					&rego.Function{
+						Name:    snapshot_testing.MatchBuiltin.Name,
+						Decl:    snapshot_testing.MatchBuiltin.Decl,
+						Memoize: false,
+					},
+					snapshot_testing.MatchTestImpl(false),
+				),
+			},
+		}).
+		SetCompiler(compiler).
+		EnableTracing(options.Verbose).
+		SetStore(store).
+		SetModules(consumer.Modules)
+	if options.Coverage != nil {
+		runner = runner.SetCoverageQueryTracer(options.Coverage)
+	}
+	ch, err := runner.RunTests(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*tester.Result
+	dup := make(chan *tester.Result)
+	go func() {
+		defer close(dup)
+		for tr := range ch {
+			results = append(results, tr)
+			dup <- tr
+		}
+	}()
+
+	reporter := tester.PrettyReporter{
+		Output:      options.Output,
+		FailureLine: true,
+		Verbose:     options.Verbose,
+	}
+	if err := reporter.Report(dup); err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		fmt.Fprintln(reporter.Output, "no test cases found")
+	}
+	return results, nil
+}
@@ -0,0 +1,84 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/khulnasoft/policy-engine/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunRegoTests(t *testing.T) {
+	fsys := fstest.MapFS{
+		"rules/TEST_001/main_test.rego": &fstest.MapFile{Data: []byte(`package rules.TEST_001_test
+
+test_pass {
+	true
+}
+
+test_fail {
+	false
+}
+`)},
+	}
+	buf := &bytes.Buffer{}
+	results, err := runRegoTests(context.Background(), regoTestOptions{
+		Providers: []data.Provider{data.FSProvider(fsys, ".")},
+		Output:    buf,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	statuses := map[string]status{}
+	for _, r := range regoTestResultsFromTester(results) {
+		assert.Equal(t, "data.rules.TEST_001_test", r.Package)
+		statuses[r.Name] = r.Status
+	}
+	assert.Equal(t, map[string]status{
+		"test_pass": statusPassed,
+		"test_fail": statusFailed,
+	}, statuses)
+	assert.Contains(t, buf.String(), "FAIL: 1/2")
+}
+
+func TestRunRegoTestsPackages(t *testing.T) {
+	fsys := fstest.MapFS{
+		"rules/TEST_001/main_test.rego": &fstest.MapFile{Data: []byte(`package rules.TEST_001_test
+
+test_one {
+	true
+}
+`)},
+		"rules/TEST_002/main_test.rego": &fstest.MapFile{Data: []byte(`package rules.TEST_002_test
+
+test_two {
+	true
+}
+`)},
+	}
+	results, err := runRegoTests(context.Background(), regoTestOptions{
+		Providers: []data.Provider{data.FSProvider(fsys, ".")},
+		Output:    &bytes.Buffer{},
+		Packages:  []string{"data.rules.TEST_001"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "data.rules.TEST_001_test", results[0].Package)
+	assert.Equal(t, "test_one", results[0].Name)
+}
@@ -0,0 +1,263 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/khulnasoft/policy-engine/pkg/data"
+	"github.com/khulnasoft/policy-engine/pkg/engine"
+	"github.com/khulnasoft/policy-engine/pkg/models"
+	"github.com/khulnasoft/policy-engine/pkg/policy"
+	"github.com/khulnasoft/policy-engine/pkg/rego"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/topdown"
+)
+
+// multipleResourceType is the resource type of rules that evaluate all
+// resources at once.
+const multipleResourceType = "MULTIPLE"
+
+// judgementRules are the rule names that policy-engine evaluates to produce
+// results.
+var judgementRules = []string{"deny", "allow", "policy"}
+
+// replayer replays the queries that the engine runs for a rule against an
+// input, with a tracer attached. The engine doesn't accept query tracers, so
+// this is how we collect coverage and profiles for specs.
+//
+// The queries, the judgement rule names and the input documents are copied
+// from policy-engine v0.1.0 (pkg/engine/policyset.go and pkg/policy). They
+// need to be checked whenever policy-engine is upgraded.
+// TestReplayMatchesEngine fails when the replayed results drift from the
+// engine's.
+type replayer struct {
+	state *rego.State
+	// modules are the non-test modules in the project.
+	modules map[string]*ast.Module
+	// rulePackages maps rule IDs to their packages.
+	rulePackages map[string]string
+}
+
+func newReplayer(ctx context.Context, eng *engine.Engine, providers []data.Provider) (*replayer, error) {
+	consumer := engine.NewPolicyConsumer()
+	if err := policy.RegoAPIProvider(ctx, consumer); err != nil {
+		return nil, err
+	}
+	if err := data.PureRegoLibProvider()(ctx, consumer); err != nil {
+		return nil, err
+	}
+	projectConsumer := engine.NewPolicyConsumer()
+	for _, provider := range providers {
+		if err := provider(ctx, consumer); err != nil {
+			return nil, err
+		}
+		if err := provider(ctx, projectConsumer); err != nil {
+			return nil, err
+		}
+	}
+	state, err := rego.NewState(rego.Options{
+		Modules:      consumer.Modules,
+		Document:     consumer.Document,
+		Capabilities: policy.Capabilities(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	modules := map[string]*ast.Module{}
+	for path, m := range projectConsumer.Modules {
+		if !strings.HasSuffix(path, "_test.rego") {
+			modules[path] = m
+		}
+	}
+
+	metadata, err := eng.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rulePackages := map[string]string{}
+	for _, mdr := range metadata {
+		if mdr.Metadata.ID != "" {
+			rulePackages[mdr.Metadata.ID] = mdr.Package
+		}
+	}
+
+	return &replayer{
+		state:        state,
+		modules:      modules,
+		rulePackages: rulePackages,
+	}, nil
+}
+
+// judgement is the value of a judgement rule in a replayed evaluation.
+type judgement struct {
+	// resourceID is the ID of the evaluated resource. It's empty for rules
+	// that evaluate all resources at once.
+	resourceID string
+	rule       string
+	value      ast.Value
+}
+
+// replay replays the evaluation of the given rule against an input. It returns
+// the values of the judgement rules that are defined for the input.
+func (c *replayer) replay(ctx context.Context, ruleID string, input *models.State, tracer topdown.QueryTracer) ([]judgement, error) {
+	pkg, ok := c.rulePackages[ruleID]
+	if !ok {
+		return nil, fmt.Errorf("package not found for rule %s", ruleID)
+	}
+	resourcesQuery := policy.NewResourcesQueryCache(policy.NewInputResolver(input))
+	// Mirrors the relations cache that the engine precomputes for each input.
+	relations := &policy.RelationsCache{
+		Forward:  ast.NewObject(),
+		Backward: ast.NewObject(),
+	}
+	for _, r := range []struct {
+		query string
+		value *ast.Value
+	}{
+		{"data.khulnasoft.internal.relations.forward", &relations.Forward},
+		{"data.khulnasoft.internal.relations.backward", &relations.Backward},
+	} {
+		value := r.value
+		defined := false
+		err := c.query(ctx, tracer, r.query, multiResourceInput(), policy.NewBuiltins(input, resourcesQuery, nil),
+			func(v ast.Value) error {
+				*value = v
+				defined = true
+				return nil
+			})
+		if err != nil {
+			return nil, err
+		}
+		if !defined {
+			return nil, fmt.Errorf("%s is undefined, the replayed queries don't match the policy engine", r.query)
+		}
+	}
+	builtins := policy.NewBuiltins(input, resourcesQuery, relations)
+
+	// The engine also reads these rules, so they shouldn't show up as
+	// uncovered.
+	for _, name := range []string{"input_type", "metadata"} {
+		if err := c.query(ctx, tracer, fmt.Sprintf("%s.%s", pkg, name), nil, builtins, nil); err != nil {
+			return nil, err
+		}
+	}
+	var resourceType string
+	err := c.query(ctx, tracer, pkg+".resource_type", nil, builtins, func(v ast.Value) error {
+		return rego.Bind(v, &resourceType)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var judgements []judgement
+	collect := func(resourceID string, rule string) func(ast.Value) error {
+		return func(v ast.Value) error {
+			judgements = append(judgements, judgement{
+				resourceID: resourceID,
+				rule:       rule,
+				value:      v,
+			})
+			return nil
+		}
+	}
+	if resourceType == multipleResourceType {
+		for _, name := range judgementRules {
+			query := fmt.Sprintf("%s.%s", pkg, name)
+			if err := c.query(ctx, tracer, query, multiResourceInput(), builtins, collect("", name)); err != nil {
+				return nil, err
+			}
+		}
+		if err := c.query(ctx, tracer, pkg+".resources", multiResourceInput(), builtins, nil); err != nil {
+			return nil, err
+		}
+		return judgements, nil
+	}
+	resources := input.Resources[resourceType]
+	ids := make([]string, 0, len(resources))
+	for id := range resources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		doc, err := resourceInput(resources[id])
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range judgementRules {
+			query := fmt.Sprintf("%s.%s", pkg, name)
+			if err := c.query(ctx, tracer, query, doc, builtins, collect(id, name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return judgements, nil
+}
+
+func (c *replayer) query(
+	ctx context.Context,
+	tracer topdown.QueryTracer,
+	query string,
+	input ast.Value,
+	builtins *policy.Builtins,
+	process func(ast.Value) error,
+) error {
+	if process == nil {
+		process = func(ast.Value) error { return nil }
+	}
+	return c.state.Query(ctx, rego.Query{
+		Query:    query,
+		Input:    input,
+		Builtins: builtins.Implementations(),
+		Tracers:  []topdown.QueryTracer{tracer},
+	}, process)
+}
+
+func multiResourceInput() ast.Value {
+	return ast.NewObject(
+		[2]*ast.Term{ast.StringTerm("resources"), ast.ObjectTerm()},
+	)
+}
+
+// resourceInput returns the input document that the engine uses when
+// evaluating a single-resource rule.
+func resourceInput(resource models.ResourceState) (ast.Value, error) {
+	obj := map[string]interface{}{}
+	for k, attr := range resource.Attributes {
+		obj[k] = attr
+	}
+	// If the resource has a non-blank ID attribute, it takes precedence over
+	// the logical ID.
+	if id, ok := obj["id"].(string); !ok || id == "" {
+		obj["id"] = resource.Id
+	}
+	obj["_id"] = resource.Id
+	obj["_type"] = resource.ResourceType
+	obj["_namespace"] = resource.Namespace
+	obj["_meta"] = map[string]interface{}{}
+	if resource.Meta != nil {
+		obj["_meta"] = resource.Meta
+	}
+	tags := map[string]interface{}{}
+	for k, v := range resource.Tags {
+		tags[k] = v
+	}
+	obj["_tags"] = tags
+	return ast.InterfaceToValue(obj)
+}
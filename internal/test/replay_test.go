@@ -0,0 +1,170 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"github.com/khulnasoft/policy-engine/pkg/data"
+	"github.com/khulnasoft/policy-engine/pkg/engine"
+	"github.com/khulnasoft/policy-engine/pkg/models"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/cover"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var replayFS = fstest.MapFS{
+	"lib/relations.rego": &fstest.MapFile{Data: []byte(`package relations
+
+import data.khulnasoft
+
+relations[info] {
+	info := khulnasoft.relation_from_fields(
+		"bucket_logging",
+		{"aws_s3_bucket": ["id"]},
+		{"aws_s3_bucket_logging": ["bucket"]},
+	)
+}
+`)},
+	"rules/TEST_001/main.rego": &fstest.MapFile{Data: []byte(`package rules.TEST_001
+
+input_type := "tf"
+
+resource_type := "aws_s3_bucket"
+
+metadata := {"id": "TEST-001"}
+
+deny {
+	input.acl == "public-read"
+}
+`)},
+	"rules/TEST_002/main.rego": &fstest.MapFile{Data: []byte(`package rules.TEST_002
+
+import data.khulnasoft
+
+input_type := "tf"
+
+resource_type := "MULTIPLE"
+
+metadata := {"id": "TEST-002"}
+
+buckets := khulnasoft.resources("aws_s3_bucket")
+
+deny[info] {
+	bucket := buckets[_]
+	count(khulnasoft.relates(bucket, "bucket_logging")) == 0
+	info := {"resource": bucket}
+}
+
+resources[info] {
+	bucket := buckets[_]
+	info := {"resource": bucket}
+}
+`)},
+}
+
+func replayInput() models.State {
+	bucket := func(id string, acl string) models.ResourceState {
+		return models.ResourceState{
+			Id:           id,
+			ResourceType: "aws_s3_bucket",
+			Namespace:    "main.tf",
+			Attributes:   map[string]interface{}{"acl": acl},
+		}
+	}
+	return models.State{
+		InputType:           "tf_hcl",
+		EnvironmentProvider: "iac",
+		Scope:               map[string]interface{}{"filepath": "main.tf"},
+		Resources: map[string]map[string]models.ResourceState{
+			"aws_s3_bucket": {
+				"aws_s3_bucket.private": bucket("aws_s3_bucket.private", "private"),
+				"aws_s3_bucket.public":  bucket("aws_s3_bucket.public", "public-read"),
+			},
+			"aws_s3_bucket_logging": {
+				"aws_s3_bucket_logging.private": {
+					Id:           "aws_s3_bucket_logging.private",
+					ResourceType: "aws_s3_bucket_logging",
+					Namespace:    "main.tf",
+					Attributes:   map[string]interface{}{"bucket": "aws_s3_bucket.private"},
+				},
+			},
+		},
+	}
+}
+
+// failingResources returns the IDs of the resources that fail a rule
+// according to the replayed judgements.
+func failingResources(t *testing.T, judgements []judgement) []string {
+	ids := []string{}
+	for _, j := range judgements {
+		require.Equal(t, "deny", j.rule)
+		if j.resourceID != "" {
+			if j.value.Compare(ast.Boolean(true)) == 0 {
+				ids = append(ids, j.resourceID)
+			}
+			continue
+		}
+		set, ok := j.value.(ast.Set)
+		require.True(t, ok)
+		set.Foreach(func(info *ast.Term) {
+			id := info.Get(ast.StringTerm("resource")).Get(ast.StringTerm("_id"))
+			ids = append(ids, string(id.Value.(ast.String)))
+		})
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// TestReplayMatchesEngine checks that replaying a rule gives the same results
+// as evaluating it with the engine, so that coverage and profiles keep
+// measuring what the engine actually runs.
+func TestReplayMatchesEngine(t *testing.T) {
+	ctx := context.Background()
+	providers := []data.Provider{data.FSProvider(replayFS, ".")}
+	eng := engine.NewEngine(ctx, &engine.EngineOptions{Providers: providers})
+	rep, err := newReplayer(ctx, eng, providers)
+	require.NoError(t, err)
+	input := replayInput()
+
+	for _, ruleID := range []string{"TEST-001", "TEST-002"} {
+		t.Run(ruleID, func(t *testing.T) {
+			results := eng.Eval(ctx, &engine.EvalOptions{
+				Inputs:  []models.State{input},
+				RuleIDs: []string{ruleID},
+			})
+			require.Len(t, results.Results, 1)
+			expected := []string{}
+			for _, rr := range results.Results[0].RuleResults {
+				require.Empty(t, rr.Errors)
+				for _, r := range rr.Results {
+					if !r.Passed {
+						expected = append(expected, r.ResourceId)
+					}
+				}
+			}
+			sort.Strings(expected)
+
+			judgements, err := rep.replay(ctx, ruleID, &input, cover.New())
+			require.NoError(t, err)
+			assert.NotEmpty(t, expected)
+			assert.Equal(t, expected, failingResources(t, judgements))
+		})
+	}
+}
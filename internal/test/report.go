@@ -0,0 +1,308 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/open-policy-agent/opa/tester"
+	"github.com/spf13/afero"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/utils"
+)
+
+const (
+	reportFormatJUnit = "junit"
+	reportFormatJSON  = "json"
+)
+
+func reportFormats() []string {
+	return []string{
+		reportFormatJUnit,
+		reportFormatJSON,
+	}
+}
+
+func validateReportFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, f := range reportFormats() {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported report format %q, expected one of %v", format, reportFormats())
+}
+
+type status string
+
+const (
+	statusPassed  status = "passed"
+	statusFailed  status = "failed"
+	statusSkipped status = "skipped"
+	// statusErrored is used for specs that could not be checked, e.g. because
+	// the engine failed on their input.
+	statusErrored status = "errored"
+)
+
+// report contains the results of a test run.
+type report struct {
+	// Passed is the result of passed, stored for the JSON report.
+	Passed    bool             `json:"passed"`
+	Specs     []specResult     `json:"specs"`
+	RegoTests []regoTestResult `json:"rego_tests"`
+	// CoverageBelowThreshold lists the rule directories whose coverage is
+	// below the minimum that was requested.
+	CoverageBelowThreshold []string `json:"coverage_below_threshold,omitempty"`
+	// Problems lists gaps in the project's specs. They only fail the run in
+	// strict mode.
+	Problems []specProblem  `json:"problems,omitempty"`
+	Profiles []*specProfile `json:"profiles,omitempty"`
+	strict   bool
+}
+
+// passed returns whether all specs and rego tests in the report passed and
+// all rules met the coverage threshold. In strict mode, the report must also
+// have no spec problems. Skipped rego tests are not considered
+// to be passing.
+func (r *report) passed() bool {
+	if len(r.CoverageBelowThreshold) > 0 {
+		return false
+	}
+	if r.strict && len(r.Problems) > 0 {
+		return false
+	}
+	for _, s := range r.Specs {
+		if s.Status != statusPassed {
+			return false
+		}
+	}
+	for _, t := range r.RegoTests {
+		if t.Status != statusPassed {
+			return false
+		}
+	}
+	return true
+}
+
+// specResult is the outcome of running a single rule spec.
+type specResult struct {
+	RuleID   string        `json:"rule_id"`
+	Input    string        `json:"input"`
+	Expected string        `json:"expected"`
+	Status   status        `json:"status"`
+	Diff     string        `json:"diff,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// regoTestResult is the outcome of running a single rego test.
+type regoTestResult struct {
+	Package  string        `json:"package"`
+	Name     string        `json:"name"`
+	File     string        `json:"file,omitempty"`
+	Row      int           `json:"row,omitempty"`
+	Status   status        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+func regoTestResultsFromTester(results []*tester.Result) []regoTestResult {
+	out := make([]regoTestResult, 0, len(results))
+	for _, r := range results {
+		result := regoTestResult{
+			Package:  r.Package,
+			Name:     r.Name,
+			Status:   statusPassed,
+			Duration: r.Duration,
+		}
+		if r.Location != nil {
+			result.File = r.Location.File
+			result.Row = r.Location.Row
+		}
+		switch {
+		case r.Skip:
+			result.Status = statusSkipped
+		case r.Error != nil:
+			result.Status = statusFailed
+			result.Error = r.Error.Error()
+		case r.Fail:
+			result.Status = statusFailed
+			if r.FailedAt != nil {
+				result.Error = fmt.Sprintf("failed at %s", r.FailedAt.Location)
+			}
+		}
+		out = append(out, result)
+	}
+	return out
+}
+
+// reportOptions configure the report that is written after a test run.
+type reportOptions struct {
+	format string
+	file   string
+}
+
+// write writes the report when a report format was given.
+func (o reportOptions) write(r *report) error {
+	if o.format == "" {
+		return nil
+	}
+	return writeReport(r, o.format, o.file)
+}
+
+// writeReport writes the report in the given format to the given path. When
+// the path is empty, the report is written to stdout.
+func writeReport(r *report, format string, path string) error {
+	buf := &bytes.Buffer{}
+	var err error
+	switch format {
+	case reportFormatJUnit:
+		err = writeJUnitReport(buf, r)
+	case reportFormatJSON:
+		err = writeJSONReport(buf, r)
+	default:
+		err = validateReportFormat(format)
+	}
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		_, err = buf.WriteTo(os.Stdout)
+		return err
+	}
+	return utils.WriteFileAtomic(afero.NewOsFs(), path, buf.Bytes(), 0644)
+}
+
+func writeJSONReport(w io.Writer, r *report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+
+	duration time.Duration
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message  string `xml:"message,attr"`
+	Contents string `xml:",cdata"`
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func (s *junitTestSuite) add(c junitTestCase, d time.Duration) {
+	s.Tests += 1
+	if c.Failure != nil {
+		s.Failures += 1
+	}
+	if c.Error != nil {
+		s.Errors += 1
+	}
+	if c.Skipped != nil {
+		s.Skipped += 1
+	}
+	s.Cases = append(s.Cases, c)
+	s.duration += d
+	s.Time = junitTime(s.duration)
+}
+
+func writeJUnitReport(w io.Writer, r *report) error {
+	specs := junitTestSuite{Name: "specs", Time: junitTime(0)}
+	for _, s := range r.Specs {
+		c := junitTestCase{
+			Name:      s.Input,
+			Classname: s.RuleID,
+			File:      s.Input,
+			Time:      junitTime(s.Duration),
+		}
+		switch s.Status {
+		case statusFailed:
+			c.Failure = &junitFailure{
+				Message:  "expected output does not match",
+				Contents: s.Diff,
+			}
+		case statusErrored:
+			c.Error = &junitFailure{
+				Message:  "error running spec",
+				Contents: s.Error,
+			}
+		}
+		specs.add(c, s.Duration)
+	}
+
+	regoTests := junitTestSuite{Name: "rego tests", Time: junitTime(0)}
+	for _, t := range r.RegoTests {
+		c := junitTestCase{
+			Name:      t.Name,
+			Classname: t.Package,
+			File:      t.File,
+			Time:      junitTime(t.Duration),
+		}
+		switch t.Status {
+		case statusFailed:
+			c.Failure = &junitFailure{
+				Message:  "rego test failed",
+				Contents: t.Error,
+			}
+		case statusSkipped:
+			c.Skipped = &struct{}{}
+		}
+		regoTests.add(c, t.Duration)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{specs, regoTests}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
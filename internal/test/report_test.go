@@ -0,0 +1,180 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/tester"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/cli-extension-iac-rules/internal/project"
+)
+
+func TestValidateReportFormat(t *testing.T) {
+	assert.NoError(t, validateReportFormat(""))
+	assert.NoError(t, validateReportFormat(reportFormatJUnit))
+	assert.NoError(t, validateReportFormat(reportFormatJSON))
+	assert.EqualError(t, validateReportFormat("tap"), `unsupported report format "tap", expected one of [junit json]`)
+}
+
+func TestRegoTestResultsFromTester(t *testing.T) {
+	results := regoTestResultsFromTester([]*tester.Result{
+		{
+			Package:  "data.rules.TEST_001_test",
+			Name:     "test_pass",
+			Location: &ast.Location{File: "rules/TEST_001/main_test.rego", Row: 3},
+			Duration: time.Second,
+		},
+		{Package: "data.rules.TEST_001_test", Name: "test_fail", Fail: true},
+		{Package: "data.rules.TEST_001_test", Name: "test_error", Error: errors.New("eval error")},
+		{Package: "data.rules.TEST_001_test", Name: "todo_test_skip", Skip: true},
+	})
+	assert.Equal(t, []regoTestResult{
+		{
+			Package:  "data.rules.TEST_001_test",
+			Name:     "test_pass",
+			File:     "rules/TEST_001/main_test.rego",
+			Row:      3,
+			Status:   statusPassed,
+			Duration: time.Second,
+		},
+		{Package: "data.rules.TEST_001_test", Name: "test_fail", Status: statusFailed},
+		{Package: "data.rules.TEST_001_test", Name: "test_error", Status: statusFailed, Error: "eval error"},
+		{Package: "data.rules.TEST_001_test", Name: "todo_test_skip", Status: statusSkipped},
+	}, results)
+}
+
+func TestWriteReportJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	r := &report{
+		Specs: []specResult{
+			{RuleID: "TEST-001", Input: "a.tf", Expected: "a.json", Status: statusPassed},
+		},
+		RegoTests: []regoTestResult{
+			{Package: "data.rules.TEST_001_test", Name: "test_pass", Status: statusPassed},
+		},
+	}
+	require.NoError(t, writeReport(r, reportFormatJSON, path))
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var decoded report
+	require.NoError(t, json.Unmarshal(contents, &decoded))
+	assert.Equal(t, r, &decoded)
+}
+
+func TestWriteReportUnsupportedFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.txt")
+	assert.Error(t, writeReport(&report{}, "tap", path))
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	r := &report{
+		Specs: []specResult{
+			{RuleID: "TEST-001", Input: "a.tf", Status: statusPassed},
+			{RuleID: "TEST-001", Input: "b.tf", Status: statusFailed, Diff: "diff"},
+		},
+		RegoTests: []regoTestResult{
+			{Package: "data.rules.TEST_001_test", Name: "test_pass", Status: statusPassed},
+			{Package: "data.rules.TEST_001_test", Name: "todo_test_skip", Status: statusSkipped},
+		},
+	}
+	buf := &bytes.Buffer{}
+	require.NoError(t, writeJUnitReport(buf, r))
+	out := buf.String()
+	assert.Contains(t, out, `<testsuite name="specs" tests="2" failures="1" errors="0" skipped="0"`)
+	assert.Contains(t, out, `<failure message="expected output does not match"><![CDATA[diff]]></failure>`)
+	assert.Contains(t, out, `<testsuite name="rego tests" tests="2" failures="0" errors="0" skipped="1"`)
+}
+
+func TestCheckSpecErrored(t *testing.T) {
+	options := testOptions{
+		fs:       afero.NewMemMapFs(),
+		comparer: comparer{mode: compareModeText},
+	}
+	eval := &specEvaluation{
+		fixture: &project.RuleSpec{
+			RuleDirName: "TEST_001",
+			Input:       project.ExistingFile("spec/rules/TEST_001/inputs/infra.tf"),
+		},
+		ruleID: "TEST-001",
+		err:    errors.New("failed to parse input"),
+	}
+	result := &specResult{Status: statusPassed}
+	err := checkSpec(context.Background(), options, normalizer{}, nil, eval, result)
+	assert.EqualError(t, err, "failed to parse input")
+}
+
+func TestReportPassed(t *testing.T) {
+	testCases := []struct {
+		name     string
+		statuses []status
+		expected bool
+	}{
+		{name: "passed", statuses: []status{statusPassed, statusPassed}, expected: true},
+		{name: "failed", statuses: []status{statusPassed, statusFailed}, expected: false},
+		{name: "errored", statuses: []status{statusPassed, statusErrored}, expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &report{}
+			for _, s := range tc.statuses {
+				r.Specs = append(r.Specs, specResult{Status: s})
+			}
+			assert.Equal(t, tc.expected, r.passed())
+		})
+	}
+}
+
+func TestWriteJUnitReportErroredSpec(t *testing.T) {
+	r := &report{
+		Specs: []specResult{
+			{RuleID: "TEST-001", Input: "a.tf", Status: statusPassed},
+			{RuleID: "TEST-001", Input: "b.tf", Status: statusFailed, Diff: "diff"},
+			{RuleID: "TEST-002", Input: "c.tf", Status: statusErrored, Error: "failed to parse input"},
+		},
+	}
+	buf := &bytes.Buffer{}
+	assert.NoError(t, writeJUnitReport(buf, r))
+	out := buf.String()
+	assert.Contains(t, out, `<testsuite name="specs" tests="3" failures="1" errors="1" skipped="0"`)
+	assert.Contains(t, out, `<error message="error running spec"><![CDATA[failed to parse input]]></error>`)
+	assert.Equal(t, 1, strings.Count(out, "<failure "))
+}
+
+func TestWriteJSONReportPassed(t *testing.T) {
+	r := &report{
+		Specs: []specResult{
+			{RuleID: "TEST-001", Input: "a.tf", Status: statusFailed},
+		},
+	}
+	r.Passed = r.passed()
+	buf := &bytes.Buffer{}
+	assert.NoError(t, writeJSONReport(buf, r))
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, false, decoded["passed"])
+}
@@ -16,21 +16,22 @@ package test
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/hexops/gotextdiff"
-	"github.com/hexops/gotextdiff/myers"
-	"github.com/hexops/gotextdiff/span"
 	"github.com/khulnasoft-lab/go-application-framework/pkg/configuration"
 	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
 	"github.com/khulnasoft/policy-engine/pkg/engine"
 	"github.com/khulnasoft/policy-engine/pkg/models"
 	"github.com/khulnasoft/policy-engine/pkg/postprocess"
-	"github.com/khulnasoft/policy-engine/pkg/rego/test"
 	"github.com/spf13/afero"
 	"github.com/spf13/pflag"
 
@@ -40,13 +41,51 @@ import (
 
 const (
 	flagUpdateExpected = "update-expected"
+	flagReportFormat   = "report-format"
+	flagReportFile     = "report-file"
+	flagParallelism    = "parallelism"
+	flagRule           = "rule"
+	flagSpec           = "spec"
+	flagWatch          = "watch"
+	flagCompare        = "compare"
+	flagIgnoreOrder    = "ignore-array-order"
+	flagCoverage       = "coverage"
+	flagCoverageFormat = "coverage-format"
+	flagCoverageFile   = "coverage-file"
+	flagCoverageMin    = "coverage-threshold"
+	flagStrict         = "strict"
+	flagPruneExpected  = "prune-expected"
+	flagProfile        = "profile"
+	flagIterations     = "profile-iterations"
+	flagTimeout        = "timeout"
 )
 
+// dataTypeReport is the type of the workflow data containing the test report.
+const dataTypeReport = "report"
+
 func RegisterWorkflows(e workflow.Engine) error {
 	workflowID := workflow.NewWorkflowIdentifier("iac.rules.test")
 	flagset := pflag.NewFlagSet("vulnmap-cli-extension-iac-rules-test", pflag.ExitOnError)
 
 	flagset.Bool(flagUpdateExpected, false, "Updated expected JSON files based on actual results")
+	flagset.String(flagReportFormat, "", "Write a report of all test results in the given format (junit or json)")
+	flagset.String(flagReportFile, "", "File to write the test report to (defaults to stdout)")
+	flagset.Int(flagParallelism, runtime.NumCPU(), "Number of specs to evaluate concurrently")
+	flagset.StringSlice(flagRule, []string{}, "Only run specs and rego tests for rules whose ID matches one of these globs")
+	flagset.StringSlice(flagSpec, []string{}, "Only run specs whose name matches one of these globs")
+	flagset.Bool(flagWatch, false, "Watch the project for changes and rerun the affected specs and rego tests")
+	flagset.String(flagCompare, compareModeText, "How to compare expected and actual output (text or semantic)")
+	flagset.Bool(flagIgnoreOrder, false, "Ignore the order of arrays when comparing output semantically")
+	flagset.Bool(flagCoverage, false, "Report rego line coverage from specs and rego tests")
+	flagset.String(flagCoverageFormat, coverageFormatLcov, "Format of the coverage file (lcov or cobertura)")
+	flagset.String(flagCoverageFile, "", "Write the coverage report to this file")
+	flagset.Float64(flagCoverageMin, 0, "Fail if the coverage of any rule is below this percentage")
+	flagset.Bool(flagPruneExpected, false, "Remove expected output files whose input no longer exists")
+	flagset.Bool(flagProfile, false, "Measure the latency, allocations and rego hot spots of each rule on its specs")
+	flagset.Int(flagIterations, 10, "Number of times each spec is evaluated when profiling")
+	flagset.Duration(flagTimeout, engine.DefaultEvalTimeout, "Maximum time to evaluate a rule against a single spec")
+	utils.AddProjectDirFlag(flagset)
+	flagset.Bool(flagStrict, false, "Fail if any spec directory has no rule, any rule has no specs or any spec has no expected output")
 
 	c := workflow.ConfigurationOptionsFromFlagset(flagset)
 
@@ -60,17 +99,133 @@ func testWorkflow(
 	ictx workflow.InvocationContext,
 	_ []workflow.Data,
 ) ([]workflow.Data, error) {
-	ctx := context.Background()
-	verbose := ictx.GetConfiguration().GetBool(configuration.DEBUG)
+	ctx, cancel := utils.SignalContext()
+	defer cancel()
+	config := ictx.GetConfiguration()
+	reportFormat := config.GetString(flagReportFormat)
+	reportFile := config.GetString(flagReportFile)
+	if err := validateReportFormat(reportFormat); err != nil {
+		return nil, err
+	}
+	compareMode := config.GetString(flagCompare)
+	if err := validateCompareMode(compareMode); err != nil {
+		return nil, err
+	}
+	coverageFormat := config.GetString(flagCoverageFormat)
+	if err := validateCoverageFormat(coverageFormat); err != nil {
+		return nil, err
+	}
+	timeout, err := utils.GetDuration(config, flagTimeout)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := newTestFilter(
+		config.GetStringSlice(flagRule),
+		config.GetStringSlice(flagSpec),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rego test output goes to stderr when stdout is used for the report.
+	var regoOutput io.Writer = os.Stdout
+	if reportFormat != "" && reportFile == "" {
+		regoOutput = os.Stderr
+	}
+	fsys := afero.NewOsFs()
+	root, err := project.Discover(fsys, config.GetString(utils.FlagProjectDir))
+	if err != nil {
+		return nil, err
+	}
+	options := testOptions{
+		fs:             fsys,
+		root:           root,
+		filter:         filter,
+		comparer:       comparer{mode: compareMode, ignoreArrayOrder: config.GetBool(flagIgnoreOrder)},
+		updateExpected: config.GetBool(flagUpdateExpected),
+		pruneExpected:  config.GetBool(flagPruneExpected),
+		parallelism:    config.GetInt(flagParallelism),
+		timeout:        timeout,
+		strict:         config.GetBool(flagStrict),
+		verbose:        config.GetBool(configuration.DEBUG),
+		regoOutput:     regoOutput,
+		report: reportOptions{
+			format: reportFormat,
+			file:   reportFile,
+		},
+		profile: profileOptions{
+			enabled:    config.GetBool(flagProfile),
+			iterations: config.GetInt(flagIterations),
+		},
+		coverage: coverageOptions{
+			enabled:   config.GetBool(flagCoverage),
+			format:    coverageFormat,
+			file:      config.GetString(flagCoverageFile),
+			threshold: config.GetFloat64(flagCoverageMin),
+		},
+	}
+
+	if config.GetBool(flagWatch) {
+		return nil, watchTests(ctx, options)
+	}
+
+	rpt, err := runTests(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := options.report.write(rpt); err != nil {
+		return nil, err
+	}
 
+	// The results are returned even when the tests failed, so that callers
+	// can tell which ones did.
+	data, err := utils.NewJSONData(ictx.GetWorkflowIdentifier(), dataTypeReport, rpt)
+	if err != nil {
+		return nil, err
+	}
+	if !rpt.Passed {
+		return []workflow.Data{data}, fmt.Errorf("tests failed")
+	}
+
+	return []workflow.Data{data}, nil
+}
+
+type testOptions struct {
+	fs             afero.Fs
+	root           string
+	filter         *testFilter
+	comparer       comparer
+	updateExpected bool
+	pruneExpected  bool
+	parallelism    int
+	timeout        time.Duration
+	strict         bool
+	verbose        bool
+	regoOutput     io.Writer
+	report         reportOptions
+	coverage       coverageOptions
+	profile        profileOptions
+}
+
+type coverageOptions struct {
+	enabled   bool
+	format    string
+	file      string
+	threshold float64
+}
+
+// runTests loads the project and runs its specs and rego tests.
+func runTests(ctx context.Context, options testOptions) (*report, error) {
 	fmt.Fprintln(os.Stderr, "Running specs...")
 
-	updateExpected := ictx.GetConfiguration().GetBool(flagUpdateExpected)
+	fs := options.fs
+	rpt := &report{}
 	fixturesFailed := 0
+	fixturesErrored := 0
 	fixturesTested := 0
 
-	fs := afero.NewOsFs()
-	prj, err := project.FromDir(fs, ".")
+	prj, err := project.FromDir(fs, options.root)
 	if err != nil {
 		return nil, err
 	}
@@ -80,79 +235,316 @@ func testWorkflow(
 		return nil, err
 	}
 
+	filter, err := options.filter.resolveRuleDirs(ctx, eng)
+	if err != nil {
+		return nil, err
+	}
+
 	ruleDirNameToRuleID, err := makeRuleDirNameToRuleID(eng, ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	rulePackages, err := filter.rulePackages(ctx, eng)
+	if err != nil {
+		return nil, err
+	}
+
+	ruleInputTypes, err := project.RuleInputTypes(ctx, eng)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.pruneExpected {
+		if err := pruneExpected(fs, prj, ruleDirNameToRuleID, filter); err != nil {
+			return nil, err
+		}
+	}
+
+	var rep *replayer
+	if options.coverage.enabled || options.profile.enabled {
+		rep, err = newReplayer(ctx, eng, prj.Providers())
+		if err != nil {
+			return nil, err
+		}
+	}
+	var cov *coverage
+	if options.coverage.enabled {
+		cov = newCoverage(rep)
+	}
+
+	var evals []*specEvaluation
 	for _, fixture := range prj.RuleSpecs() {
+		if !filter.matchesSpec(fixture) {
+			continue
+		}
 		ruleID, ok := ruleDirNameToRuleID[fixture.RuleDirName]
+		if !filter.matchesRule(ruleID, fixture.RuleDirName) {
+			continue
+		}
+		if !ok && !options.strict {
+			// Specs without a rule are listed by findSpecProblems below, and
+			// only fail the run with --strict.
+			continue
+		}
+		eval := &specEvaluation{
+			fixture:       fixture,
+			ruleID:        ruleID,
+			ruleInputType: ruleInputTypes[ruleID],
+		}
 		if !ok {
-			return nil, fmt.Errorf("ID metadata not found for %s", fixture.RuleDirName)
+			eval.err = fmt.Errorf("no rule found for spec directory %s", fixture.RuleDirName)
 		}
+		evals = append(evals, eval)
+	}
 
-		actualResults, err := runEngine(eng, ruleID, fixture.Input.Path())
+	engines := []*engine.Engine{eng}
+	for len(engines) < options.parallelism && len(engines) < len(evals) {
+		e, err := prj.Engine(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("Error running engine on %v: %w", fixture.Input.Path(), err)
+			return nil, err
 		}
-		actualBytes, err := json.MarshalIndent(actualResults, "", "  ")
-		actual := string(actualBytes)
+		engines = append(engines, e)
+	}
 
-		var expected string
-		expectedPath := fixture.ExpectedPath()
-		expectedFile, err := fs.Open(expectedPath)
-		if err == nil {
-			expectedBytes, err := io.ReadAll(expectedFile)
-			if err != nil {
-				return nil, err
-			}
-			expected = string(expectedBytes)
-			expectedFile.Close()
-		}
+	// Specs are evaluated concurrently, but everything that prints output or
+	// writes files happens below, in spec order.
+	norm := normalizer{}
+	if specs := prj.Manifest().Specs; specs != nil {
+		norm.ignoreFields = specs.IgnoreFields
+	}
+	evaluateSpecs(ctx, engines, evals, norm, options.timeout)
 
-		if expected != actual {
+	for _, eval := range evals {
+		// Stop before anything else is written when the run was interrupted.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result := specResult{
+			RuleID:   eval.ruleID,
+			Input:    eval.fixture.Input.Path(),
+			Expected: eval.fixture.ExpectedPath(),
+			Status:   statusPassed,
+			Duration: eval.duration,
+		}
+		// Errors are recorded against the spec rather than aborting the run so
+		// that one broken spec doesn't hide the results of the others.
+		if err := checkSpec(ctx, options, norm, cov, eval, &result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running spec %v: %s\n", eval.fixture.Input.Path(), err)
+			result.Status = statusErrored
+			result.Error = err.Error()
+		}
+		switch result.Status {
+		case statusFailed:
 			fixturesFailed += 1
-			edits := myers.ComputeEdits(span.URI(expectedPath), expected, actual)
-			diff := gotextdiff.ToUnified(expectedPath, fixture.Input.Path(), expected, edits)
-			fmt.Fprintf(os.Stderr, "expected output does not match for rule %s\n: %s", ruleID, diff)
-
-			if updateExpected {
-				if err := os.MkdirAll(filepath.Dir(expectedPath), 0755); err != nil {
-					return nil, err
-				}
-				fixture.UpdateExpected(actualBytes)
-				if err := fixture.WriteChanges(fs); err != nil {
-					return nil, err
-				}
+		case statusErrored:
+			fixturesErrored += 1
+		}
+		rpt.Specs = append(rpt.Specs, result)
+		fixturesTested += 1
+	}
+
+	fmt.Fprintf(os.Stderr, "%d/%d specs passed, %d failed, %d errored.\n",
+		fixturesTested-fixturesFailed-fixturesErrored, fixturesTested, fixturesFailed, fixturesErrored)
+
+	if options.profile.enabled {
+		// Profiling happens after all specs have been evaluated so that the
+		// measurements aren't affected by concurrent evaluations.
+		for _, eval := range evals {
+			if eval.err != nil {
+				continue
+			}
+			p, err := profileSpec(ctx, eng, rep, eval, options.profile.iterations)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
 			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error profiling %v: %s\n", eval.fixture.Input.Path(), err)
+				continue
+			}
+			writeProfile(os.Stderr, p)
+			rpt.Profiles = append(rpt.Profiles, p)
 		}
+	}
 
-		fixturesTested += 1
+	rpt.strict = options.strict
+	rpt.Problems = findSpecProblems(specProblemsOptions{
+		root:                  options.root,
+		specRuleDirs:          prj.ListRuleSpecDirs(),
+		fixtures:              prj.RuleSpecs(),
+		ruleDirNameToRuleID:   ruleDirNameToRuleID,
+		filter:                filter,
+		ignoreMissingExpected: options.updateExpected,
+	})
+	if len(rpt.Problems) > 0 {
+		fmt.Fprintf(os.Stderr, "Found %d problems with specs:\n", len(rpt.Problems))
+		for _, p := range rpt.Problems {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", p.Path, p.message())
+		}
 	}
 
-	fmt.Fprintf(os.Stderr, "%d/%d specs passed.\n", fixturesTested-fixturesFailed, fixturesTested)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	// As well as the "specs" (snapshot tests) we also use policy-engine/test to
-	// run custom rego tests.
+	// As well as the "specs" (snapshot tests) we also run custom rego tests.
 	fmt.Fprintln(os.Stderr, "Running rego tests...")
-	result, err := test.Test(ctx, test.Options{
+	regoResults, err := runRegoTests(ctx, regoTestOptions{
 		Providers: prj.Providers(),
-		Verbose:   verbose,
+		Verbose:   options.verbose,
+		Output:    options.regoOutput,
+		Packages:  rulePackages,
+		Coverage:  cov.tracer(),
 	})
 	if err != nil {
 		return nil, err
 	}
+	rpt.RegoTests = regoTestResultsFromTester(regoResults)
 
-	if fixturesFailed > 0 || !result.Passed {
-		return nil, fmt.Errorf("tests failed")
+	if cov != nil {
+		coverageReport := cov.report(func(ruleDirName string) bool {
+			return filter.matchesRule(ruleDirNameToRuleID[ruleDirName], ruleDirName)
+		})
+		writeCoverageSummary(os.Stderr, coverageReport)
+		if options.coverage.file != "" {
+			if err := writeCoverageFile(coverageReport, options.coverage.format, options.coverage.file); err != nil {
+				return nil, err
+			}
+		}
+		for _, rc := range rulesBelowThreshold(coverageReport, options.coverage.threshold) {
+			fmt.Fprintf(os.Stderr, "coverage for rule %s is %.2f%%, below the threshold of %.2f%%\n",
+				rc.ruleDirName, rc.percentage(), options.coverage.threshold)
+			rpt.CoverageBelowThreshold = append(rpt.CoverageBelowThreshold, rc.ruleDirName)
+		}
 	}
 
-	return []workflow.Data{}, nil
+	rpt.Passed = rpt.passed()
+	return rpt, nil
+}
+
+// checkSpec compares the output of an evaluated spec against its expected
+// output and assertions, and records the outcome in result. It returns an
+// error when the spec could not be checked at all.
+func checkSpec(ctx context.Context, options testOptions, norm normalizer, cov *coverage, eval *specEvaluation, result *specResult) error {
+	fs := options.fs
+	fixture := eval.fixture
+	if eval.err != nil {
+		return eval.err
+	}
+	if cov != nil {
+		for i := range eval.inputs.States {
+			if err := cov.traceSpec(ctx, eval.ruleID, &eval.inputs.States[i]); err != nil {
+				return fmt.Errorf("error collecting coverage: %w", err)
+			}
+		}
+	}
+	actualBytes := eval.actual
+	actual := string(actualBytes)
+	var diffs []string
+
+	if fixture.Assertions != nil {
+		failures, err := checkAssertionsFile(fs, fixture.AssertionsPath(), actualBytes)
+		if err != nil {
+			return err
+		}
+		if len(failures) > 0 {
+			diff := strings.Join(failures, "\n") + "\n"
+			fmt.Fprintf(os.Stderr, "assertions failed for rule %s\n: %s", eval.ruleID, diff)
+			diffs = append(diffs, diff)
+		}
+	}
+
+	// Specs with only an assertions file are not compared against a
+	// snapshot.
+	if fixture.Assertions == nil || fixture.Expected != nil {
+		expectedPath := fixture.ExpectedPath()
+		expected, err := readExpected(fs, expectedPath)
+		if err != nil {
+			return fmt.Errorf("error reading expected output %v: %w", expectedPath, err)
+		}
+		// Snapshots written before normalization was introduced, or with
+		// other ignored fields, are normalized so that they still pass. In
+		// text mode, the actual output then goes through the same
+		// normalization, so that both sides are formatted alike. Snapshots
+		// that are already normalized are compared byte for byte.
+		inputsDir := filepath.Dir(fixture.Input.Path())
+		compareExpected, compareActual := expected, actual
+		normalized, changed := norm.normalizeExpected(expected, inputsDir)
+		if options.comparer.mode == compareModeSemantic {
+			compareExpected = normalized
+		} else if changed {
+			compareExpected = normalized
+			compareActual, _ = norm.normalizeExpected(actual, inputsDir)
+		}
+
+		ok, diff := options.comparer.compare(expectedPath, fixture.Input.Path(), compareExpected, compareActual)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "expected output does not match for rule %s\n: %s", eval.ruleID, diff)
+			diffs = append(diffs, diff)
+		}
+
+		// Snapshots that only match semantically are rewritten too, so that
+		// they are stored in normalized form.
+		if options.updateExpected && expected != actual {
+			if err := fs.MkdirAll(filepath.Dir(expectedPath), 0755); err != nil {
+				return err
+			}
+			fixture.UpdateExpected(actualBytes)
+			if err := fixture.WriteChanges(fs); err != nil {
+				return err
+			}
+		}
+	} else {
+		result.Expected = fixture.AssertionsPath()
+	}
+
+	if len(diffs) > 0 {
+		result.Status = statusFailed
+		result.Diff = strings.Join(diffs, "\n")
+	}
+	return nil
+}
+
+// readExpected reads an expected output file. A missing file is treated as
+// empty so that it shows up in the diff.
+func readExpected(fsys afero.Fs, path string) (string, error) {
+	b, err := afero.ReadFile(fsys, path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// pruneExpected removes the expected output and assertion files that no longer
+// have a matching input from the spec directories selected by the filter.
+func pruneExpected(fsys afero.Fs, prj *project.Project, ruleDirNameToRuleID map[string]string, filter *testFilter) error {
+	dirs := prj.ListRuleSpecDirs()
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if !filter.matchesRule(ruleDirNameToRuleID[dir], dir) {
+			continue
+		}
+		for _, f := range prj.StaleExpectedFiles(dir) {
+			fmt.Fprintf(os.Stderr, "Removing stale expected file %s\n", f.Path())
+			f.Delete()
+			if err := f.WriteChanges(fsys); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func makeRuleDirNameToRuleID(eng *engine.Engine, ctx context.Context) (map[string]string, error) {
+	metadata, err := eng.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
 	out := map[string]string{}
-	for _, mdr := range eng.Metadata(ctx) {
+	for _, mdr := range metadata {
 		if ruleID := mdr.Metadata.ID; ruleID != "" {
 			ruleDirName, err := project.RuleIDToSafeFileName(ruleID)
 			if err != nil {
@@ -164,21 +556,35 @@ func makeRuleDirNameToRuleID(eng *engine.Engine, ctx context.Context) (map[strin
 	return out, nil
 }
 
-func runEngine(eng *engine.Engine, ruleID string, path string) ([]models.RuleResult, error) {
-	singleInput, err := utils.LoadSingleInput(path)
-	if err != nil {
-		return nil, err
-	}
-	ctx := context.Background()
-	inputs := []models.State{singleInput.State}
+// loadInputMu serializes input loading. Some of the policy-engine loaders
+// lazily initialize shared state, e.g. the Terraform schemas, and are not safe
+// to call concurrently.
+var loadInputMu sync.Mutex
+
+func loadInput(path string) (*utils.Inputs, error) {
+	loadInputMu.Lock()
+	defer loadInputMu.Unlock()
+	return utils.LoadInputs(path)
+}
+
+// runEngine evaluates a rule against all states of a spec input and returns
+// the aggregated results, in the order of the states.
+func runEngine(ctx context.Context, eng *engine.Engine, ruleID string, inputs *utils.Inputs) ([]models.RuleResult, error) {
 	results := eng.Eval(ctx, &engine.EvalOptions{
-		Inputs:  inputs,
+		Inputs:  inputs.States,
 		RuleIDs: []string{ruleID},
 	})
-	postprocess.AddSourceLocs(results, singleInput.Loader)
+	postprocess.AddSourceLocs(results, inputs.Loader)
 
-	if len(results.Results) != 1 || len(results.Results[0].RuleResults) != 1 {
-		return nil, fmt.Errorf("internal error: expected a single rule result")
+	if len(results.Results) != len(inputs.States) {
+		return nil, fmt.Errorf("internal error: expected %d results but got %d", len(inputs.States), len(results.Results))
+	}
+	var ruleResults []models.RuleResult
+	for _, r := range results.Results {
+		if len(r.RuleResults) != 1 {
+			return nil, fmt.Errorf("internal error: expected a single rule result")
+		}
+		ruleResults = append(ruleResults, r.RuleResults[0].Results...)
 	}
-	return results.Results[0].RuleResults[0].Results, nil
+	return ruleResults, nil
 }
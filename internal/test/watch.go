@@ -0,0 +1,166 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long we wait for further changes before rerunning
+// tests. Editors often produce several events for a single save.
+const watchDebounce = 200 * time.Millisecond
+
+// watchedDirs are the project directories that trigger a rerun when changed.
+var watchedDirs = []string{"rules", "lib", "spec"}
+
+// watchTests runs all selected tests, then watches the project for changes
+// and reruns the tests that are affected by each change. It only returns when
+// the context is cancelled or the watcher fails. Cancellation, e.g. because
+// the user pressed Ctrl-C, is the normal way to stop watching and is not
+// reported as an error.
+func watchTests(ctx context.Context, options testOptions) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	for _, d := range watchedDirs {
+		if err := watchRecursive(watcher, filepath.Join(options.root, d)); err != nil {
+			return err
+		}
+	}
+
+	rerun := func(filter *testFilter) {
+		opts := options
+		opts.filter = filter
+		rpt, err := runTests(ctx, opts)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			// The report is rewritten after every run, so that it always
+			// reflects the latest results.
+			err = opts.report.write(rpt)
+		}
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		case rpt.passed():
+			fmt.Fprintln(os.Stderr, "Tests passed.")
+		default:
+			fmt.Fprintln(os.Stderr, "Tests failed.")
+		}
+		fmt.Fprintln(os.Stderr, "Watching for changes...")
+	}
+	rerun(options.filter)
+
+	// changed holds the rule directories that changed since the last run. A
+	// nil map means that everything should be rerun.
+	changed := map[string]bool{}
+	pending := false
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return nil
+			}
+			return ctx.Err()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchRecursive(watcher, event.Name); err != nil {
+						return err
+					}
+				}
+			}
+			ruleDir, ok := affectedRuleDir(options.root, event.Name)
+			if !ok {
+				changed = nil
+			} else if changed != nil {
+				changed[ruleDir] = true
+			}
+			pending = true
+			timer.Reset(watchDebounce)
+		case <-timer.C:
+			if !pending {
+				continue
+			}
+			filter := options.filter
+			if changed != nil {
+				filter = filter.withRuleDirs(changed)
+			}
+			rerun(filter)
+			changed = map[string]bool{}
+			pending = false
+		}
+	}
+}
+
+func watchRecursive(watcher *fsnotify.Watcher, root string) error {
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// affectedRuleDir returns the rule directory name that is affected by a change
+// to the given path. It returns false when the change could affect any rule,
+// e.g. for changes to the lib directory.
+func affectedRuleDir(root string, path string) (string, bool) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	switch {
+	case len(parts) >= 3 && parts[0] == "rules":
+		return parts[1], true
+	case len(parts) >= 4 && parts[0] == "spec" && parts[1] == "rules":
+		return parts[2], true
+	default:
+		return "", false
+	}
+}
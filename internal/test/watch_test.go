@@ -0,0 +1,118 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAffectedRuleDir(t *testing.T) {
+	for _, tc := range []struct {
+		path       string
+		expected   string
+		expectedOk bool
+	}{
+		{
+			path:       "project/rules/TEST_001/main.rego",
+			expected:   "TEST_001",
+			expectedOk: true,
+		},
+		{
+			path:       "project/spec/rules/TEST_001/inputs/infra.tf",
+			expected:   "TEST_001",
+			expectedOk: true,
+		},
+		{
+			path:       "project/lib/relations.rego",
+			expectedOk: false,
+		},
+		{
+			path:       "project/rules/shared.rego",
+			expectedOk: false,
+		},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			ruleDir, ok := affectedRuleDir("project", tc.path)
+			assert.Equal(t, tc.expected, ruleDir)
+			assert.Equal(t, tc.expectedOk, ok)
+		})
+	}
+}
+
+func TestWatchTestsCancelled(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(`{"name":"Test"}`), 0644))
+	filter, err := newTestFilter(nil, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = watchTests(ctx, testOptions{
+		fs:          afero.NewOsFs(),
+		root:        dir,
+		filter:      filter,
+		comparer:    comparer{mode: compareModeText},
+		parallelism: 1,
+		regoOutput:  io.Discard,
+	})
+	assert.NoError(t, err)
+}
+
+func TestWatchTestsWritesReport(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(`{"name":"Test"}`), 0644))
+	reportFile := filepath.Join(dir, "report.json")
+	filter, err := newTestFilter(nil, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go func() {
+		// Stop watching once the first run wrote its report.
+		for ctx.Err() == nil {
+			if _, err := os.Stat(reportFile); err == nil {
+				cancel()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	err = watchTests(ctx, testOptions{
+		fs:          afero.NewOsFs(),
+		root:        dir,
+		filter:      filter,
+		comparer:    comparer{mode: compareModeText},
+		parallelism: 1,
+		regoOutput:  io.Discard,
+		report: reportOptions{
+			format: reportFormatJSON,
+			file:   reportFile,
+		},
+	})
+	assert.NoError(t, err)
+	contents, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	var rpt report
+	assert.NoError(t, json.Unmarshal(contents, &rpt))
+	assert.True(t, rpt.Passed)
+}
@@ -0,0 +1,97 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testutil contains fixtures and helpers that are shared by the tests
+// of several packages.
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/khulnasoft-lab/go-application-framework/pkg/configuration"
+	"github.com/khulnasoft-lab/go-application-framework/pkg/networking"
+	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// Rule is a single-resource rule with the ID TEST-001.
+const Rule = `package rules.TEST_001
+
+input_type := "tf"
+resource_type := "aws_s3_bucket"
+
+metadata := {
+	"id": "TEST-001",
+	"severity": "high",
+	"title": "S3 bucket has the word 'bucket' in its name",
+	"description": "The word 'bucket' is redundant in a bucket name.",
+	"product": ["iac"]
+}
+
+deny[info] {
+	contains(input.bucket, "bucket")
+	info := {"resource": input}
+}
+`
+
+// Lib is a lib module that Rule could use.
+const Lib = `package lib.utils
+
+is_bucket(name) {
+	contains(name, "bucket")
+}
+`
+
+// ProjectFiles returns the files of a project named "Test" that contains Rule
+// and Lib.
+func ProjectFiles() map[string]string {
+	return map[string]string{
+		"manifest.json":            `{"name":"Test"}`,
+		"rules/TEST_001/main.rego": Rule,
+		"lib/utils.rego":           Lib,
+	}
+}
+
+// WriteFiles writes the given files, keyed by their slash-separated path, to
+// dir.
+func WriteFiles(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+	for path, contents := range files {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+}
+
+// InvocationContext returns an invocation context for the given workflow that
+// sends API requests according to config.
+func InvocationContext(workflowID string, config configuration.Configuration) workflow.InvocationContext {
+	// The network logger reads the body of failed responses when tracing, so
+	// it is disabled to let the API errors through.
+	logger := zerolog.Nop()
+	network := networking.NewNetworkAccess(config)
+	network.SetLogger(&logger)
+	return workflow.NewInvocationContext(
+		workflow.NewWorkflowIdentifier(workflowID),
+		config,
+		nil,
+		network,
+		logger,
+		nil,
+		nil,
+	)
+}
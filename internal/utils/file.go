@@ -0,0 +1,60 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it
+// into place, so that an interrupted write never leaves a partial file behind.
+// An existing file keeps its mode, and perm is only used for new files.
+func WriteFileAtomic(fsys afero.Fs, path string, data []byte, perm os.FileMode) error {
+	if info, err := fsys.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := afero.TempFile(fsys, dir, "."+name+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		fsys.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		fsys.Remove(tmpPath)
+		return err
+	}
+	if err := fsys.Chmod(tmpPath, perm); err != nil {
+		fsys.Remove(tmpPath)
+		return err
+	}
+	if err := fsys.Rename(tmpPath, path); err != nil {
+		fsys.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	fsys := afero.NewOsFs()
+	dir := t.TempDir()
+
+	t.Run("new file", func(t *testing.T) {
+		path := filepath.Join(dir, "new.json")
+		require.NoError(t, WriteFileAtomic(fsys, path, []byte("new"), 0600))
+		contents, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(contents))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("existing file keeps its mode", func(t *testing.T) {
+		path := filepath.Join(dir, "manifest.json")
+		require.NoError(t, os.WriteFile(path, []byte("old"), 0644))
+		require.NoError(t, os.Chmod(path, 0664))
+		require.NoError(t, WriteFileAtomic(fsys, path, []byte("updated"), 0644))
+		contents, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "updated", string(contents))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0664), info.Mode().Perm())
+	})
+
+	t.Run("no temporary files are left behind", func(t *testing.T) {
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+	})
+}
@@ -0,0 +1,26 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import "github.com/spf13/pflag"
+
+// FlagProjectDir is the flag shared by all workflows to select the custom
+// rules project.
+const FlagProjectDir = "project-dir"
+
+// AddProjectDirFlag adds the --project-dir flag to the given flag set.
+func AddProjectDirFlag(flagset *pflag.FlagSet) {
+	flagset.String(FlagProjectDir, ".", "Directory in the custom rules project. The closest directory with a manifest.json, starting here and walking up, is used as the project root")
+}
@@ -28,6 +28,34 @@ type SingleInput struct {
 }
 
 func LoadSingleInput(path string) (*SingleInput, error) {
+	inputs, err := LoadInputs(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(inputs.States) != 1 {
+		return nil, fmt.Errorf("internal error: expected a single input but got %d", len(inputs.States))
+	}
+	return &SingleInput{State: inputs.States[0], Loader: inputs.Loader}, nil
+}
+
+// Inputs holds all of the states that were loaded from a file or directory.
+// A directory can produce several states, e.g. one per Kubernetes manifest.
+type Inputs struct {
+	States []models.State
+	Loader input.Loader // Can be used to call AddSourceLocs
+}
+
+// LoadInputs loads all IaC inputs from the given file or directory. It returns
+// an error if no inputs were found.
+func LoadInputs(path string) (inputs *Inputs, err error) {
+	// Some loaders panic on malformed inputs, e.g. Terraform plans without a
+	// configuration section.
+	defer func() {
+		if r := recover(); r != nil {
+			inputs = nil
+			err = fmt.Errorf("failed to load %s: %v", path, r)
+		}
+	}()
 	detector, err := input.DetectorByInputTypes(input.Types{input.Auto})
 	if err != nil {
 		return nil, err
@@ -43,9 +71,22 @@ func LoadSingleInput(path string) (*SingleInput, error) {
 	if err != nil {
 		return nil, err
 	}
+	if dir, ok := detectable.(*input.Directory); ok {
+		// Keep walking even when the directory itself was loaded, since it
+		// can contain other configurations, e.g. Kubernetes manifests next to
+		// a Terraform module. Files that are part of a loaded configuration
+		// are not loaded again.
+		err := dir.Walk(func(d input.Detectable, depth int) (bool, error) {
+			_, err := loader.Load(d, input.DetectOptions{})
+			return false, err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 	states := loader.ToStates()
-	if len(states) != 1 {
-		return nil, fmt.Errorf("internal error: expected a single input but got %d", len(states))
+	if len(states) < 1 {
+		return nil, fmt.Errorf("no inputs found in %s", path)
 	}
-	return &SingleInput{State: states[0], Loader: loader}, nil
+	return &Inputs{States: states, Loader: loader}, nil
 }
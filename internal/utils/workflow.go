@@ -0,0 +1,34 @@
+// © 2023 Khulnasoft Limited All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/json"
+
+	"github.com/khulnasoft-lab/go-application-framework/pkg/workflow"
+)
+
+// ContentTypeJSON is the content type of the data returned by our workflows.
+const ContentTypeJSON = "application/json"
+
+// NewJSONData encodes v as JSON and wraps it in workflow data of the given
+// type, so that other workflows and the host CLI can consume it.
+func NewJSONData(workflowID workflow.Identifier, dataType string, v interface{}) (workflow.Data, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return workflow.NewData(workflow.NewTypeIdentifier(workflowID, dataType), ContentTypeJSON, b), nil
+}